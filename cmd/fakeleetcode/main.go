@@ -0,0 +1,53 @@
+// Command fakeleetcode serves a fake LeetCode GraphQL endpoint for local runs.
+// Start it, then point the app at it:
+//
+//	go run ./cmd/fakeleetcode -synthetic 100000
+//	LEETCODE_URL=http://localhost:8089/graphql go run ./cmd/main.go
+//
+// Faults can be changed while it runs through PUT /_fake/faults.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/fakeleetcode"
+)
+
+func main() {
+	addr := flag.String("addr", ":8089", "listen address")
+	rankingFile := flag.String("ranking", "tests/fetched_first_page_users.json", "recorded globalRanking response ranked first, empty for none")
+	usersFile := flag.String("users", "leetcode_users.json", "user profiles answered by matchedUser, empty for none")
+	synthetic := flag.Int("synthetic", 10000, "generated users ranked after the fixture ones")
+	perPage := flag.Int("per-page", fakeleetcode.DefaultUsersPerPage, "users per ranking page")
+
+	var faults fakeleetcode.Faults
+	flag.DurationVar(&faults.Latency, "latency", 0, "delay added to every response")
+	flag.DurationVar(&faults.Jitter, "jitter", 0, "random extra delay, up to this much")
+	flag.Float64Var(&faults.ThrottleRate, "throttle-rate", 0, "share of requests answered with 429")
+	flag.DurationVar(&faults.RetryAfter, "retry-after", 0, "Retry-After sent with a 429")
+	flag.Float64Var(&faults.MalformedRate, "malformed-rate", 0, "share of responses with truncated JSON")
+	flag.StringVar(&faults.Encoding, "encoding", "", "response encoding: gzip, br, random or empty for none")
+	flag.Parse()
+
+	fake := fakeleetcode.New()
+	if *rankingFile != "" {
+		if err := fake.LoadRankingFile(*rankingFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *usersFile != "" {
+		if err := fake.LoadUsersFile(*usersFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fake.SetSyntheticUsers(*synthetic)
+	fake.SetUsersPerPage(*perPage)
+	fake.SetFaults(faults)
+
+	log.Printf("fake leetcode listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, fake); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -23,12 +23,13 @@ import (
 	"github.com/gin-gonic/gin"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
-	"github.com/robfig/cron"
 	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
 	_ "github.com/ruziba3vich/leetcode_ranking/docs"
 	custom_http "github.com/ruziba3vich/leetcode_ranking/internal/http"
+	"github.com/ruziba3vich/leetcode_ranking/internal/outbox"
 	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
 	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/helper"
+	"github.com/ruziba3vich/leetcode_ranking/internal/scheduler"
 	"github.com/ruziba3vich/leetcode_ranking/internal/service"
 	"github.com/ruziba3vich/leetcode_ranking/internal/storage"
 	logger "github.com/ruziba3vich/prodonik_lgger"
@@ -45,15 +46,19 @@ func main() {
 			helper.NewDB,
 			storage.NewStorage,
 			newUsersStorage,
-			service.NewLeetCodeClient,
+			newLeetCodeSource,
 			service.NewUserService,
+			newScheduler,
+			newOutboxDispatcher,
 			custom_http.NewHandler,
 			newEngine,
 		),
 		fx.Invoke(
-			// startCron,
 			registerHandlerRoutes,
 			runHTTPServer,
+			runScheduler,
+			runOutboxDispatcher,
+			manageSync,
 		),
 	).Run()
 }
@@ -70,14 +75,40 @@ func newUsersStorage(db *sql.DB) users_storage.Querier {
 	return users_storage.New(db)
 }
 
+// newLeetCodeSource provides the GraphQL client as the service's LeetCode source.
+// The client itself is pointed at cmd/fakeleetcode by LEETCODE_URL, or at
+// recorded cassettes by LEETCODE_CASSETTE_MODE.
+func newLeetCodeSource(cfg *config.Config) service.LeetCodeSource {
+	return service.NewLeetCodeClient(cfg)
+}
+
 func registerHandlerRoutes(h *custom_http.Handler, router *gin.Engine) {
 	api := router.Group("/api/v1/")
 	{
 		api.POST("/add-user", h.CreateUser)
 		api.GET("/get-users", h.GetUsersByCountry)
+		api.GET("/users/:username/history", h.GetUserHistory)
 		api.POST("/sync-leaderboard", h.SyncLeaderboard)
 		api.POST("/stop-syncing", h.StopSyncing)
 		api.GET("/sync-status", h.GetSyncingStatus)
+		api.POST("/sync/pause", h.PauseSync)
+		api.POST("/sync/resume", h.ResumeSync)
+		api.POST("/sync/cancel", h.CancelSync)
+		api.GET("/sync-jobs", h.ListSyncJobs)
+		api.GET("/sync-jobs/:id/leases", h.ListSyncJobLeases)
+		api.GET("/sync-jobs/:id/report", h.GetSyncJobReport)
+		api.GET("/failed-users", h.ListFailedUsers)
+		api.POST("/failed-users/retry", h.RetryFailedUsers)
+		api.GET("/accounts", h.ListAccounts)
+		api.GET("/schedules", h.ListSchedules)
+		api.PUT("/schedules/:name", h.UpdateSchedule)
+		api.GET("/schedules/:name/runs", h.ListScheduleRuns)
+		api.GET("/tracked-groups", h.ListTrackedGroups)
+		api.POST("/tracked-groups", h.CreateTrackedGroup)
+		api.DELETE("/tracked-groups/:name", h.DeleteTrackedGroup)
+		api.GET("/tracked-groups/:name/members", h.ListTrackedGroupMembers)
+		api.POST("/tracked-groups/:name/members", h.AddTrackedGroupMembers)
+		api.DELETE("/tracked-groups/:name/members/:username", h.RemoveTrackedGroupMember)
 	}
 }
 
@@ -130,21 +161,81 @@ func runHTTPServer(
 	})
 }
 
-func startCron(srv service.UserService) {
-	log.Println("cron started")
-	c := cron.New()
+// manageSync resumes a sync job that was still running when the process stopped,
+// starts the worker that takes page leases of distributed jobs, and drains both
+// before shutdown
+func manageSync(lc fx.Lifecycle, srv service.UserService, log *logger.Logger) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := srv.ResumeInterruptedSync(ctx); err != nil {
+				log.Error("failed to resume interrupted sync", map[string]any{"error": err})
+			}
+			srv.StartLeaseWorker()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Info("Stopping sync jobs...")
+			return srv.Shutdown(ctx)
+		},
+	})
+}
+
+// newScheduler registers the recurring jobs with their default specs from config
+func newScheduler(cfg *config.Config, storage users_storage.Querier, srv service.UserService, log *logger.Logger) *scheduler.Scheduler {
+	s := scheduler.New(storage, log, cfg.Leases.ReplicaID)
+
+	s.Register(scheduler.FullSync, cfg.Scheduler.FullSync, func(ctx context.Context) error {
+		// Pages: 0 sweeps up to the last ranking page; an interrupted sweep is
+		// resumed from its checkpoint on the next start
+		return srv.SyncLeaderboard(ctx, service.SyncOptions{
+			StartPage: 1,
+			Workers:   cfg.Scheduler.SyncWorkers,
+			Mode:      service.SyncMode(cfg.Scheduler.SyncMode),
+			MaxAge:    cfg.Scheduler.SyncMaxAge,
+			// one replica claims the run and leases the pages to all of them
+			Distributed: cfg.Scheduler.SyncDistributed,
+		})
+	})
+	s.Register(scheduler.PriorityRefresh, cfg.Scheduler.PriorityRefresh, srv.RefreshOverdueUsers)
+	s.Register(scheduler.FailedUsers, cfg.Scheduler.FailedUsers, func(ctx context.Context) error {
+		_, err := srv.RetryFailedUsers(ctx, 0)
+		return err
+	})
+	return s
+}
 
-	c.AddFunc("0 0 * * *", func() {
-		createCron(srv)
+func runScheduler(lc fx.Lifecycle, s *scheduler.Scheduler, log *logger.Logger) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return s.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Info("Stopping scheduler...")
+			return s.Stop(ctx)
+		},
 	})
 }
 
-func createCron(srv service.UserService) error {
-	ctx, cancel := context.WithTimeout(context.TODO(), time.Minute*10)
-	defer cancel()
-	opts := service.SyncOptions{
-		StartPage: 1,
-		Pages:     30164,
+// newOutboxDispatcher delivers user change events to the sinks enabled in config
+func newOutboxDispatcher(cfg *config.Config, storage users_storage.Querier, log *logger.Logger) *outbox.Dispatcher {
+	var sinks []outbox.Sink
+	if cfg.Outbox.LogEvents {
+		sinks = append(sinks, outbox.NewLogSink(log))
+	}
+	if cfg.Outbox.WebhookURL != "" {
+		sinks = append(sinks, outbox.NewWebhookSink(cfg.Outbox.WebhookURL, cfg.Outbox.WebhookTimeout))
 	}
-	return srv.SyncLeaderboard(ctx, opts)
+	return outbox.New(storage, log, cfg.Outbox, cfg.Leases.ReplicaID, sinks...)
+}
+
+func runOutboxDispatcher(lc fx.Lifecycle, d *outbox.Dispatcher, log *logger.Logger) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return d.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Info("Stopping outbox dispatcher...")
+			return d.Stop(ctx)
+		},
+	})
 }
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_user_data_rating;
+
+ALTER TABLE user_data
+    DROP COLUMN IF EXISTS contest_rating,
+    DROP COLUMN IF EXISTS global_ranking,
+    DROP COLUMN IF EXISTS contest_rankings,
+    DROP COLUMN IF EXISTS data_region;
+
+ALTER TABLE staging_user_data
+    DROP COLUMN IF EXISTS contest_rating,
+    DROP COLUMN IF EXISTS global_ranking,
+    DROP COLUMN IF EXISTS contest_rankings,
+    DROP COLUMN IF EXISTS data_region;
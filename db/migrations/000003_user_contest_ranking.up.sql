@@ -0,0 +1,13 @@
+ALTER TABLE user_data
+    ADD COLUMN IF NOT EXISTS contest_rating DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS global_ranking INT,
+    ADD COLUMN IF NOT EXISTS contest_rankings TEXT,
+    ADD COLUMN IF NOT EXISTS data_region TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_user_data_rating ON user_data(contest_rating DESC NULLS LAST);
+
+ALTER TABLE staging_user_data
+    ADD COLUMN IF NOT EXISTS contest_rating DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS global_ranking INT,
+    ADD COLUMN IF NOT EXISTS contest_rankings TEXT,
+    ADD COLUMN IF NOT EXISTS data_region TEXT;
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS idx_user_data_hard_solved;
+
+ALTER TABLE user_data
+    DROP COLUMN IF EXISTS easy_solved,
+    DROP COLUMN IF EXISTS medium_solved,
+    DROP COLUMN IF EXISTS hard_solved,
+    DROP COLUMN IF EXISTS easy_submissions,
+    DROP COLUMN IF EXISTS medium_submissions,
+    DROP COLUMN IF EXISTS hard_submissions,
+    DROP COLUMN IF EXISTS all_submissions;
+
+ALTER TABLE staging_user_data
+    DROP COLUMN IF EXISTS easy_solved,
+    DROP COLUMN IF EXISTS medium_solved,
+    DROP COLUMN IF EXISTS hard_solved,
+    DROP COLUMN IF EXISTS easy_submissions,
+    DROP COLUMN IF EXISTS medium_submissions,
+    DROP COLUMN IF EXISTS hard_submissions,
+    DROP COLUMN IF EXISTS all_submissions;
@@ -0,0 +1,21 @@
+-- *_solved hold accepted problems per difficulty, *_submissions hold every submission
+-- (accepted or not); total_submissions keeps counting accepted submissions only.
+ALTER TABLE user_data
+    ADD COLUMN IF NOT EXISTS easy_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS medium_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS hard_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS easy_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS medium_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS hard_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS all_submissions INT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_user_data_hard_solved ON user_data(hard_solved DESC);
+
+ALTER TABLE staging_user_data
+    ADD COLUMN IF NOT EXISTS easy_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS medium_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS hard_solved INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS easy_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS medium_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS hard_submissions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS all_submissions INT NOT NULL DEFAULT 0;
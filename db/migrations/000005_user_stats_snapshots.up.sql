@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_stats_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    total_problems_solved INT NOT NULL DEFAULT 0,
+    total_submissions INT NOT NULL DEFAULT 0,
+    easy_solved INT NOT NULL DEFAULT 0,
+    medium_solved INT NOT NULL DEFAULT 0,
+    hard_solved INT NOT NULL DEFAULT 0,
+    all_submissions INT NOT NULL DEFAULT 0,
+    contest_rating DOUBLE PRECISION,
+    global_ranking INT,
+    captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_stats_snapshots_user_time ON user_stats_snapshots(username, captured_at);
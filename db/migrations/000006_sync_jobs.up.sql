@@ -0,0 +1,28 @@
+-- status: running | stopped | finished | failed
+-- checkpoint_page is the last page whose users were committed; a resumed job continues from checkpoint_page + 1.
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'running',
+    start_page INT NOT NULL,
+    end_page INT NOT NULL DEFAULT 0,
+    total_pages INT NOT NULL DEFAULT 0,
+    checkpoint_page INT NOT NULL DEFAULT 0,
+    workers INT NOT NULL DEFAULT 0,
+    batch_size INT NOT NULL DEFAULT 0,
+    delay_ms INT NOT NULL DEFAULT 0,
+    processed_users INT NOT NULL DEFAULT 0,
+    failed_users INT NOT NULL DEFAULT 0,
+    failed_pages INT NOT NULL DEFAULT 0,
+    error_count INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_sync_jobs_updated
+BEFORE UPDATE ON sync_jobs
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_status ON sync_jobs(status);
@@ -0,0 +1,22 @@
+-- Dead-letter queue of users whose profile could not be fetched.
+-- status: pending (retried by the scheduled pass) | permanent (given up on)
+-- A row is deleted as soon as the user is fetched successfully.
+CREATE TABLE IF NOT EXISTS failed_user_fetches (
+    username VARCHAR(255) PRIMARY KEY,
+    page INT,
+    job_id BIGINT REFERENCES sync_jobs(id) ON DELETE SET NULL,
+    error_class TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    first_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_failed_user_fetches_updated
+BEFORE UPDATE ON failed_user_fetches
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_failed_user_fetches_status ON failed_user_fetches(status, last_attempt_at);
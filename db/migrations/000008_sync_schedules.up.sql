@@ -0,0 +1,27 @@
+-- Recurring jobs run by the scheduler. Rows are seeded from config on startup
+-- and afterwards edited through the API; spec is a standard 5-field cron
+-- expression or a descriptor such as @hourly / @every 15m.
+CREATE TABLE IF NOT EXISTS sync_schedules (
+    name TEXT PRIMARY KEY,
+    spec TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_sync_schedules_updated
+BEFORE UPDATE ON sync_schedules
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- status: running | succeeded | failed | skipped
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_name TEXT NOT NULL REFERENCES sync_schedules(name) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_name, id DESC);
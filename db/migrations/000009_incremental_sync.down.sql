@@ -0,0 +1,4 @@
+ALTER TABLE sync_jobs
+    DROP COLUMN IF EXISTS mode,
+    DROP COLUMN IF EXISTS max_age_seconds,
+    DROP COLUMN IF EXISTS skipped_users;
@@ -0,0 +1,7 @@
+-- mode: full | incremental
+-- An incremental job only re-fetches users that are missing, older than
+-- max_age_seconds or whose contest rating moved; the rest count as skipped_users.
+ALTER TABLE sync_jobs
+    ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'full',
+    ADD COLUMN IF NOT EXISTS max_age_seconds INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS skipped_users INT NOT NULL DEFAULT 0;
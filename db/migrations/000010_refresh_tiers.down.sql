@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_data_updated_at;
+DROP TABLE IF EXISTS user_refresh_tiers;
+DROP TABLE IF EXISTS tracked_group_members;
+DROP TABLE IF EXISTS tracked_groups;
@@ -0,0 +1,39 @@
+-- Named groups of users our team watches; members get the "tracked" refresh tier.
+CREATE TABLE IF NOT EXISTS tracked_groups (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_tracked_groups_updated
+BEFORE UPDATE ON tracked_groups
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS tracked_group_members (
+    group_id BIGINT NOT NULL REFERENCES tracked_groups(id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tracked_group_members_username ON tracked_group_members(username);
+
+-- tier: manual | tracked | top_n. Users without a row belong to the long tail.
+-- manual rows come from /add-user and are kept when tiers are recomputed.
+CREATE TABLE IF NOT EXISTS user_refresh_tiers (
+    username TEXT PRIMARY KEY,
+    tier TEXT NOT NULL,
+    manual BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_user_refresh_tiers_updated
+BEFORE UPDATE ON user_refresh_tiers
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_user_refresh_tiers_tier ON user_refresh_tiers(tier);
+CREATE INDEX IF NOT EXISTS idx_user_data_updated_at ON user_data(updated_at);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS sync_page_leases;
+DROP INDEX IF EXISTS idx_sync_jobs_one_distributed;
+DROP INDEX IF EXISTS idx_schedule_runs_claim;
+ALTER TABLE schedule_runs
+    DROP COLUMN IF EXISTS scheduled_at,
+    DROP COLUMN IF EXISTS heartbeat_at,
+    DROP COLUMN IF EXISTS owner;
+ALTER TABLE sync_jobs
+    DROP COLUMN IF EXISTS heartbeat_at,
+    DROP COLUMN IF EXISTS owner,
+    DROP COLUMN IF EXISTS lease_pages,
+    DROP COLUMN IF EXISTS distributed;
@@ -0,0 +1,58 @@
+-- A distributed job is swept by every replica: its page range is split into
+-- leases of lease_pages pages that replicas claim from sync_page_leases.
+-- owner is the replica running (or coordinating) the job and keeps
+-- heartbeat_at fresh while it does; another replica only takes a running job
+-- over once its heartbeat went stale.
+ALTER TABLE sync_jobs
+    ADD COLUMN IF NOT EXISTS distributed BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS lease_pages INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS owner TEXT,
+    ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+
+-- A schedule run belongs to the replica executing it, which keeps heartbeat_at
+-- fresh until the run finishes. Only runs whose heartbeat went stale are failed
+-- as interrupted, never the ones another live replica is executing.
+-- Every replica fires every schedule; the run due at scheduled_at is claimed
+-- by inserting it, so only the first replica gets to execute it.
+ALTER TABLE schedule_runs
+    ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_schedule_runs_claim
+    ON schedule_runs(schedule_name, scheduled_at);
+
+-- Replicas whose schedules fire together must not start a distributed job each
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_jobs_one_distributed
+    ON sync_jobs(distributed)
+    WHERE distributed AND status IN ('running', 'paused');
+
+-- status: pending | leased | done | failed
+-- A replica claims a pending lease, or a leased one whose lease_expires_at has
+-- passed, with FOR UPDATE SKIP LOCKED and keeps it alive with a heartbeat.
+-- checkpoint_page is the last page of the range whose users were committed; a
+-- replica taking the lease over continues from checkpoint_page + 1.
+CREATE TABLE IF NOT EXISTS sync_page_leases (
+    id BIGSERIAL PRIMARY KEY,
+    job_id BIGINT NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    start_page INT NOT NULL,
+    end_page INT NOT NULL,
+    checkpoint_page INT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    owner TEXT,
+    lease_expires_at TIMESTAMPTZ,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (job_id, start_page)
+);
+
+CREATE TRIGGER trg_sync_page_leases_updated
+BEFORE UPDATE ON sync_page_leases
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_sync_page_leases_claimable
+    ON sync_page_leases(job_id, start_page)
+    WHERE status IN ('pending', 'leased');
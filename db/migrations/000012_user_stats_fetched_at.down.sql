@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_data_stats_fetched_at;
+ALTER TABLE user_data
+    DROP COLUMN IF EXISTS stats_fetched_at;
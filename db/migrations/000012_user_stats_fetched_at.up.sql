@@ -0,0 +1,11 @@
+-- stats_fetched_at is when the solved and submission counts were last fetched
+-- with queryMatchedUser. updated_at moves on every write, including ranking-only
+-- syncs (sync_jobs.mode = 'ranking') that only touch profile, rating and rank,
+-- so refresh scheduling and incremental syncs go by this column instead.
+-- NULL means the user was only ever seen on a ranking page.
+ALTER TABLE user_data
+    ADD COLUMN IF NOT EXISTS stats_fetched_at TIMESTAMPTZ DEFAULT NOW();
+
+UPDATE user_data SET stats_fetched_at = updated_at;
+
+CREATE INDEX IF NOT EXISTS idx_user_data_stats_fetched_at ON user_data(stats_fetched_at);
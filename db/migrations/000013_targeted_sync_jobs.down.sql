@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_user_data_country_username;
+ALTER TABLE sync_jobs
+    DROP COLUMN IF EXISTS checkpoint_username,
+    DROP COLUMN IF EXISTS target_updated_before,
+    DROP COLUMN IF EXISTS target_country,
+    DROP COLUMN IF EXISTS target_usernames,
+    DROP COLUMN IF EXISTS target;
@@ -0,0 +1,13 @@
+-- target: pages | usernames | country | stale
+-- A pages job sweeps the global ranking. The other targets refresh a set of
+-- users instead: the listed usernames, everyone stored for target_country, or
+-- users whose stats were not fetched since target_updated_before. They walk
+-- the set in username order and checkpoint the last committed username.
+ALTER TABLE sync_jobs
+    ADD COLUMN IF NOT EXISTS target TEXT NOT NULL DEFAULT 'pages',
+    ADD COLUMN IF NOT EXISTS target_usernames TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS target_country TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_updated_before TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS checkpoint_username TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_user_data_country_username ON user_data(country_code, username);
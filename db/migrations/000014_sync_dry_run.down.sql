@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sync_job_seen_users;
+DROP TABLE IF EXISTS sync_job_changes;
+ALTER TABLE sync_jobs
+    DROP COLUMN IF EXISTS dry_run;
@@ -0,0 +1,26 @@
+-- A dry-run job fetches like any other sync but writes nothing to user_data;
+-- what it would have changed goes to sync_job_changes instead.
+ALTER TABLE sync_jobs
+    ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- change: new_user | field_changed | rank_moved | disappeared
+-- field, old_value and new_value are empty when they do not apply.
+CREATE TABLE IF NOT EXISTS sync_job_changes (
+    id BIGSERIAL PRIMARY KEY,
+    job_id BIGINT NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    change TEXT NOT NULL,
+    field TEXT NOT NULL DEFAULT '',
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_job_changes_job ON sync_job_changes(job_id, id);
+
+-- Every user a dry-run job saw on a ranking page, to tell which stored users disappeared
+CREATE TABLE IF NOT EXISTS sync_job_seen_users (
+    job_id BIGINT NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    PRIMARY KEY (job_id, username)
+);
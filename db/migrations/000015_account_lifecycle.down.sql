@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_user_data_user_avatar;
+DROP INDEX IF EXISTS idx_user_data_real_name;
+DROP INDEX IF EXISTS idx_user_data_account_status;
+ALTER TABLE user_data
+    DROP COLUMN IF EXISTS last_missed_at,
+    DROP COLUMN IF EXISTS renamed_to,
+    DROP COLUMN IF EXISTS miss_count,
+    DROP COLUMN IF EXISTS missing_since,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS account_status;
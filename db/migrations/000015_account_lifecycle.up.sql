@@ -0,0 +1,23 @@
+-- account_status: active | missing | renamed | deleted
+-- A user LeetCode reports as not existing becomes missing; after
+-- ACCOUNT_MISSES_TO_DELETE misses in a row it is confirmed deleted. Misses
+-- closer together than ACCOUNT_MISS_INTERVAL_HOURS count once, tracked through
+-- last_missed_at. A missing or deleted user that matches a newer account by
+-- custom avatar, or by real name, country and avatar, is renamed and points at
+-- it through renamed_to.
+-- Any successful fetch or ranking page sighting makes the user active again.
+-- Leaderboards only list active and missing users.
+ALTER TABLE user_data
+    ADD COLUMN IF NOT EXISTS account_status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS missing_since TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS miss_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS renamed_to TEXT,
+    ADD COLUMN IF NOT EXISTS last_missed_at TIMESTAMPTZ;
+
+UPDATE user_data SET last_seen_at = updated_at;
+
+CREATE INDEX IF NOT EXISTS idx_user_data_account_status ON user_data(account_status);
+CREATE INDEX IF NOT EXISTS idx_user_data_real_name ON user_data(real_name);
+CREATE INDEX IF NOT EXISTS idx_user_data_user_avatar ON user_data(user_avatar);
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trg_user_data_updated ON user_data;
+CREATE TRIGGER trg_user_data_updated
+BEFORE UPDATE ON user_data
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+DROP FUNCTION IF EXISTS set_user_data_updated_at();
+
+DROP INDEX IF EXISTS idx_user_change_events_username;
+DROP TABLE IF EXISTS user_change_events;
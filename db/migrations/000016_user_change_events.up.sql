@@ -0,0 +1,32 @@
+-- kind: new_user | solved_increased | rating_changed | country_changed
+-- Written in the same transaction as the user_data merge that caused them, only
+-- for rows whose values actually changed. old_value is empty for new users.
+CREATE TABLE IF NOT EXISTS user_change_events (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_change_events_username ON user_change_events(username, id);
+
+-- updated_at of user_data only moves when its data does: touching the freshness
+-- columns of a user whose stats did not change keeps it
+CREATE OR REPLACE FUNCTION set_user_data_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+   IF to_jsonb(NEW) - ARRAY['updated_at', 'stats_fetched_at', 'last_seen_at']
+      IS DISTINCT FROM to_jsonb(OLD) - ARRAY['updated_at', 'stats_fetched_at', 'last_seen_at'] THEN
+      NEW.updated_at = NOW();
+   END IF;
+   RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_user_data_updated ON user_data;
+CREATE TRIGGER trg_user_data_updated
+BEFORE UPDATE ON user_data
+FOR EACH ROW
+EXECUTE FUNCTION set_user_data_updated_at();
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS trg_event_outbox_updated ON event_outbox;
+DROP INDEX IF EXISTS idx_event_outbox_delivered;
+DROP INDEX IF EXISTS idx_event_outbox_pending_username;
+DROP INDEX IF EXISTS idx_event_outbox_pending;
+DROP TABLE IF EXISTS event_outbox;
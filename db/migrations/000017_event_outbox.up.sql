@@ -0,0 +1,31 @@
+-- status: pending | delivered | dead
+-- One row per user_change_events row, written in the transaction of the merge
+-- that produced the event. A dispatcher leases due rows (owner,
+-- lease_expires_at), hands them to its sinks and marks them delivered, or
+-- schedules another attempt (attempts, next_attempt_at, last_error) until
+-- OUTBOX_MAX_ATTEMPTS makes them dead. A user's row is only leased once every
+-- earlier pending row of that user is delivered or dead, so sinks get each
+-- user's events in order.
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES user_change_events(id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    owner TEXT NOT NULL DEFAULT '',
+    lease_expires_at TIMESTAMPTZ,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending ON event_outbox(id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending_username ON event_outbox(username, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_event_outbox_delivered ON event_outbox(delivered_at) WHERE status = 'delivered';
+
+CREATE TRIGGER trg_event_outbox_updated
+BEFORE UPDATE ON event_outbox
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
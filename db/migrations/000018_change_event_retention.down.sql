@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_event_outbox_delivered ON event_outbox(delivered_at) WHERE status = 'delivered';
+
+DROP INDEX IF EXISTS idx_event_outbox_event_id;
+DROP INDEX IF EXISTS idx_user_change_events_created_at;
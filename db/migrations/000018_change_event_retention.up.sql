@@ -0,0 +1,6 @@
+-- Change events are pruned by age once they are no longer pending delivery,
+-- which takes their outbox rows along (ON DELETE CASCADE)
+CREATE INDEX IF NOT EXISTS idx_user_change_events_created_at ON user_change_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_event_outbox_event_id ON event_outbox(event_id);
+
+DROP INDEX IF EXISTS idx_event_outbox_delivered;
@@ -0,0 +1,77 @@
+-- name: MarkUsersMissing :many
+-- Counts a miss for every listed user LeetCode reported as not existing, at most
+-- one per miss_interval_seconds. The misses_to_delete-th miss in a row confirms
+-- the account as deleted.
+UPDATE user_data
+SET
+  miss_count = miss_count + 1,
+  missing_since = COALESCE(missing_since, NOW()),
+  last_missed_at = NOW(),
+  account_status = CASE
+    WHEN miss_count + 1 >= sqlc.arg(misses_to_delete)::int THEN 'deleted'
+    ELSE 'missing'
+  END,
+  status_changed_at = CASE
+    WHEN account_status = 'active' OR miss_count + 1 = sqlc.arg(misses_to_delete)::int THEN NOW()
+    ELSE status_changed_at
+  END
+WHERE username = ANY(sqlc.arg(usernames)::text[])
+  AND account_status IN ('active', 'missing')
+  AND (
+    last_missed_at IS NULL
+    OR last_missed_at <= NOW() - make_interval(secs => sqlc.arg(miss_interval_seconds)::int)
+  )
+RETURNING username, account_status, miss_count;
+
+-- name: LinkRenamedUsers :many
+-- Marks listed missing or deleted users as renamed when exactly one active
+-- account created after them has the same custom (non-default) avatar, or the
+-- same real name, country and avatar. Ambiguous matches are left alone.
+WITH candidates AS (
+  SELECT o.username AS old_username, n.username AS new_username
+  FROM user_data o
+  JOIN user_data n
+    ON n.username != o.username
+   AND n.account_status = 'active'
+   AND n.created_at > o.created_at
+   AND (
+     (
+       o.user_avatar IS NOT NULL AND o.user_avatar != ''
+       AND o.user_avatar NOT ILIKE '%default_avatar%'
+       AND n.user_avatar = o.user_avatar
+     )
+     OR (
+       o.real_name IS NOT NULL AND o.real_name != ''
+       AND n.real_name = o.real_name
+       AND n.country_code IS NOT DISTINCT FROM o.country_code
+       AND n.user_avatar IS NOT DISTINCT FROM o.user_avatar
+     )
+   )
+  WHERE o.username = ANY(sqlc.arg(usernames)::text[])
+    AND o.account_status IN ('missing', 'deleted')
+),
+unambiguous AS (
+  SELECT old_username, MIN(new_username) AS new_username
+  FROM candidates
+  GROUP BY old_username
+  HAVING COUNT(*) = 1
+)
+UPDATE user_data u
+SET
+  account_status = 'renamed',
+  renamed_to = c.new_username,
+  status_changed_at = NOW()
+FROM unambiguous c
+WHERE u.username = c.old_username
+RETURNING u.username, u.renamed_to;
+
+-- name: ListUsersByAccountStatus :many
+SELECT username, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to
+FROM user_data
+WHERE account_status = $1
+ORDER BY status_changed_at DESC, username
+LIMIT $2 OFFSET $3;
+
+-- name: CountUsersByAccountStatus :one
+SELECT COUNT(*) FROM user_data
+WHERE account_status = $1;
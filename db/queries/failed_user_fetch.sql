@@ -0,0 +1,56 @@
+-- name: RecordFailedUserFetch :one
+-- Bumps the attempt counter and gives up on the user once max_attempts is
+-- reached. A user LeetCode says does not exist is recorded with max_attempts 1,
+-- so it is permanent at once; its misses are counted on the account instead.
+INSERT INTO failed_user_fetches (
+  username, page, job_id, error_class, last_error, attempts, status
+) VALUES (
+  sqlc.arg(username), sqlc.arg(page), sqlc.arg(job_id), sqlc.arg(error_class), sqlc.arg(last_error), 1,
+  CASE
+    WHEN sqlc.arg(max_attempts)::int <= 1 THEN 'permanent'
+    ELSE 'pending'
+  END
+)
+ON CONFLICT (username) DO UPDATE SET
+  page = COALESCE(EXCLUDED.page, failed_user_fetches.page),
+  job_id = COALESCE(EXCLUDED.job_id, failed_user_fetches.job_id),
+  error_class = EXCLUDED.error_class,
+  last_error = EXCLUDED.last_error,
+  attempts = failed_user_fetches.attempts + 1,
+  status = CASE
+    WHEN failed_user_fetches.attempts + 1 >= sqlc.arg(max_attempts)::int THEN 'permanent'
+    ELSE 'pending'
+  END,
+  last_attempt_at = NOW()
+RETURNING *;
+
+-- name: ListFailedUserFetches :many
+SELECT * FROM failed_user_fetches
+WHERE status = $1
+ORDER BY last_attempt_at
+LIMIT $2 OFFSET $3;
+
+-- name: CountFailedUserFetches :one
+SELECT COUNT(*) FROM failed_user_fetches
+WHERE status = $1;
+
+-- name: DeleteFailedUserFetches :exec
+DELETE FROM failed_user_fetches
+WHERE username = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: ListMissingUsersToRecheck :many
+-- Permanently failed users LeetCode said do not exist whose account is still
+-- missing and whose last miss is miss_interval_seconds old. The retry pass
+-- fetches them again so every miss is counted until the deletion is confirmed
+-- or the user comes back.
+SELECT f.username FROM failed_user_fetches f
+JOIN user_data u ON u.username = f.username
+WHERE f.status = 'permanent'
+  AND f.error_class = 'user_not_found'
+  AND u.account_status = 'missing'
+  AND (
+    u.last_missed_at IS NULL
+    OR u.last_missed_at <= NOW() - make_interval(secs => sqlc.arg(miss_interval_seconds)::int)
+  )
+ORDER BY f.last_attempt_at
+LIMIT sqlc.arg(limit_count);
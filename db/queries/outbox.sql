@@ -0,0 +1,63 @@
+-- name: ClaimOutboxEvents :many
+-- Leases up to limit_count due events, oldest first. An event is only due once
+-- every earlier event of its user is delivered or dead, so a user's events are
+-- handed out one at a time and in order. Concurrent claimers skip each other's
+-- rows instead of waiting; a lease that expires makes the event due again.
+WITH due AS (
+  SELECT o.id FROM event_outbox o
+  WHERE o.status = 'pending'
+    AND o.next_attempt_at <= NOW()
+    AND (o.lease_expires_at IS NULL OR o.lease_expires_at < NOW())
+    AND NOT EXISTS (
+      SELECT 1 FROM event_outbox earlier
+      WHERE earlier.username = o.username
+        AND earlier.status = 'pending'
+        AND earlier.id < o.id
+    )
+  ORDER BY o.id
+  LIMIT sqlc.arg(limit_count)
+  FOR UPDATE OF o SKIP LOCKED
+)
+UPDATE event_outbox o
+SET
+  owner = sqlc.arg(owner)::text,
+  lease_expires_at = NOW() + make_interval(secs => sqlc.arg(ttl_seconds)::int),
+  attempts = o.attempts + 1
+FROM due, user_change_events e
+WHERE o.id = due.id AND e.id = o.event_id
+RETURNING o.id, o.event_id, o.username, e.kind, e.old_value, e.new_value, e.created_at, o.attempts;
+
+-- name: MarkOutboxEventDelivered :execrows
+-- Affects no row once the lease was taken over by another dispatcher.
+UPDATE event_outbox
+SET
+  status = 'delivered',
+  lease_expires_at = NULL,
+  last_error = NULL,
+  delivered_at = NOW()
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'pending';
+
+-- name: RetryOutboxEvent :one
+-- Schedules another attempt after retry_after_seconds, or gives up on the
+-- event once it has been claimed max_attempts times. A dead event no longer
+-- holds back the later events of its user. Returns no row once the lease was
+-- taken over by another dispatcher.
+UPDATE event_outbox
+SET
+  status = CASE WHEN attempts >= sqlc.arg(max_attempts)::int THEN 'dead' ELSE 'pending' END,
+  next_attempt_at = NOW() + make_interval(secs => sqlc.arg(retry_after_seconds)::int),
+  lease_expires_at = NULL,
+  last_error = sqlc.arg(last_error)::text
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'pending'
+RETURNING status;
+
+-- name: DeleteExpiredChangeEvents :execrows
+-- Deletes the change events created before created_before, together with
+-- their delivered or dead outbox rows. Events still pending delivery are kept.
+DELETE FROM user_change_events e
+WHERE e.created_at < sqlc.arg(created_before)::timestamptz
+  AND NOT EXISTS (
+    SELECT 1 FROM event_outbox o
+    WHERE o.event_id = e.id AND o.status = 'pending'
+  );
+
@@ -0,0 +1,113 @@
+-- name: CreateTrackedGroup :one
+INSERT INTO tracked_groups (name)
+VALUES ($1)
+RETURNING *;
+
+-- name: GetTrackedGroupByName :one
+SELECT * FROM tracked_groups
+WHERE name = $1
+LIMIT 1;
+
+-- name: ListTrackedGroups :many
+SELECT g.id, g.name, g.created_at, COUNT(m.username)::int AS member_count
+FROM tracked_groups g
+LEFT JOIN tracked_group_members m ON m.group_id = g.id
+GROUP BY g.id
+ORDER BY g.name;
+
+-- name: DeleteTrackedGroup :exec
+DELETE FROM tracked_groups
+WHERE id = $1;
+
+-- name: AddTrackedGroupMembers :exec
+INSERT INTO tracked_group_members (group_id, username)
+SELECT sqlc.arg(group_id), unnest(sqlc.arg(usernames)::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: ListTrackedGroupMembers :many
+SELECT username FROM tracked_group_members
+WHERE group_id = $1
+ORDER BY username;
+
+-- name: RemoveTrackedGroupMember :exec
+DELETE FROM tracked_group_members
+WHERE group_id = $1 AND username = $2;
+
+-- name: MarkUserManual :exec
+INSERT INTO user_refresh_tiers (username, tier, manual)
+VALUES ($1, 'manual', TRUE)
+ON CONFLICT (username) DO UPDATE
+SET
+  tier = 'manual',
+  manual = TRUE;
+
+-- name: MarkUsersTracked :exec
+-- Moves new tracked group members to the tracked tier right away; manual users
+-- keep theirs.
+INSERT INTO user_refresh_tiers (username, tier)
+SELECT unnest(sqlc.arg(usernames)::text[]), 'tracked'
+ON CONFLICT (username) DO UPDATE
+SET tier = 'tracked'
+WHERE NOT user_refresh_tiers.manual;
+
+-- name: RecomputeRefreshTiers :exec
+-- Manual users keep their tier. Everyone else becomes tracked (member of any
+-- tracked group), top_n (among the top_n solvers of their country) or drops
+-- back to the long tail.
+WITH top_n AS (
+  SELECT username FROM (
+    SELECT username, ROW_NUMBER() OVER (
+      PARTITION BY country_code ORDER BY total_problems_solved DESC, username
+    ) AS country_rank
+    FROM user_data
+    WHERE country_code IS NOT NULL AND country_code != '' AND account_status IN ('active', 'missing')
+  ) ranked
+  WHERE country_rank <= sqlc.arg(top_n)::int
+),
+computed AS (
+  SELECT DISTINCT ON (username) username, tier FROM (
+    SELECT username, 'tracked' AS tier, 1 AS priority FROM tracked_group_members
+    UNION ALL
+    SELECT username, 'top_n', 2 FROM top_n
+  ) candidates
+  ORDER BY username, priority
+),
+dropped AS (
+  DELETE FROM user_refresh_tiers t
+  WHERE NOT t.manual
+    AND NOT EXISTS (SELECT 1 FROM computed c WHERE c.username = t.username)
+)
+INSERT INTO user_refresh_tiers (username, tier)
+SELECT username, tier FROM computed
+ON CONFLICT (username) DO UPDATE
+SET tier = EXCLUDED.tier
+WHERE NOT user_refresh_tiers.manual AND user_refresh_tiers.tier != EXCLUDED.tier;
+
+-- name: ListOverdueUsersByTier :many
+-- Users of a tier not refreshed since refreshed_before, never fetched users first.
+-- Permanently failed users are left to the dead-letter queue.
+SELECT t.username, u.stats_fetched_at
+FROM user_refresh_tiers t
+LEFT JOIN user_data u ON u.username = t.username
+WHERE t.tier = sqlc.arg(tier)
+  AND (u.stats_fetched_at IS NULL OR u.stats_fetched_at < sqlc.arg(refreshed_before))
+  AND (u.account_status IS NULL OR u.account_status IN ('active', 'missing'))
+  AND NOT EXISTS (
+    SELECT 1 FROM failed_user_fetches f
+    WHERE f.username = t.username AND f.status = 'permanent'
+  )
+ORDER BY u.stats_fetched_at NULLS FIRST
+LIMIT sqlc.arg(limit_count);
+
+-- name: ListOverdueLongTailUsers :many
+SELECT u.username, u.stats_fetched_at
+FROM user_data u
+WHERE (u.stats_fetched_at IS NULL OR u.stats_fetched_at < sqlc.arg(refreshed_before))
+  AND u.account_status IN ('active', 'missing')
+  AND NOT EXISTS (SELECT 1 FROM user_refresh_tiers t WHERE t.username = u.username)
+  AND NOT EXISTS (
+    SELECT 1 FROM failed_user_fetches f
+    WHERE f.username = u.username AND f.status = 'permanent'
+  )
+ORDER BY u.stats_fetched_at NULLS FIRST
+LIMIT sqlc.arg(limit_count);
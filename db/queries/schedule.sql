@@ -0,0 +1,81 @@
+-- name: EnsureSyncSchedule :exec
+INSERT INTO sync_schedules (name, spec, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO NOTHING;
+
+-- name: GetSyncSchedule :one
+SELECT * FROM sync_schedules
+WHERE name = $1
+LIMIT 1;
+
+-- name: ListSyncSchedules :many
+SELECT * FROM sync_schedules
+ORDER BY name;
+
+-- name: UpdateSyncSchedule :one
+UPDATE sync_schedules
+SET
+  spec = $2,
+  enabled = $3
+WHERE name = $1
+RETURNING *;
+
+-- name: ClaimScheduleRun :one
+-- Claims the run of a schedule due at scheduled_at for owner. Every replica
+-- fires the same schedule at the same time and only the first claim gets a
+-- row; the others get none and leave the run to it. While a run of the
+-- schedule is still going on a replica with a fresh heartbeat, the claimed run
+-- is recorded as skipped and finished right away.
+INSERT INTO schedule_runs (schedule_name, scheduled_at, owner, status, error, finished_at, heartbeat_at)
+SELECT
+  sqlc.arg(schedule_name)::text,
+  sqlc.arg(scheduled_at)::timestamptz,
+  sqlc.arg(owner)::text,
+  CASE WHEN b.busy THEN 'skipped' ELSE 'running' END,
+  CASE WHEN b.busy THEN 'previous run still in progress' END,
+  CASE WHEN b.busy THEN NOW() END,
+  NOW()
+FROM (
+  SELECT EXISTS (
+    SELECT 1 FROM schedule_runs r
+    WHERE r.schedule_name = sqlc.arg(schedule_name)::text
+      AND r.status = 'running'
+      AND r.heartbeat_at >= NOW() - make_interval(secs => sqlc.arg(stale_seconds)::int)
+  ) AS busy
+) b
+ON CONFLICT (schedule_name, scheduled_at) DO NOTHING
+RETURNING *;
+
+-- name: FinishScheduleRun :exec
+UPDATE schedule_runs
+SET
+  status = $2,
+  error = $3,
+  finished_at = NOW()
+WHERE id = $1;
+
+-- name: HeartbeatScheduleRuns :exec
+UPDATE schedule_runs
+SET heartbeat_at = NOW()
+WHERE owner = $1 AND status = 'running';
+
+-- name: FailStaleScheduleRuns :execrows
+-- Fails the runs whose replica stopped sending heartbeats for stale_seconds,
+-- i.e. stopped or died while executing them.
+UPDATE schedule_runs
+SET
+  status = 'failed',
+  error = 'interrupted: replica stopped',
+  finished_at = NOW()
+WHERE status = 'running'
+  AND (heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => sqlc.arg(stale_seconds)::int));
+
+-- name: ListScheduleRuns :many
+SELECT * FROM schedule_runs
+WHERE schedule_name = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListLatestScheduleRuns :many
+SELECT DISTINCT ON (schedule_name) * FROM schedule_runs
+ORDER BY schedule_name, id DESC;
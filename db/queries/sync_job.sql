@@ -0,0 +1,120 @@
+-- name: CreateSyncJob :one
+INSERT INTO sync_jobs (
+  start_page, end_page, checkpoint_page, workers, batch_size, delay_ms, mode, max_age_seconds, distributed, lease_pages,
+  target, target_usernames, target_country, target_updated_before, dry_run, owner, heartbeat_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW()
+)
+RETURNING *;
+
+-- name: GetSyncJob :one
+SELECT * FROM sync_jobs
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetLatestSyncJob :one
+SELECT * FROM sync_jobs
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetInterruptedSyncJob :one
+-- The newest running job whose owner has not sent a heartbeat for
+-- stale_seconds, i.e. stopped or died. Distributed jobs are not resumed here:
+-- their leases are picked up by the lease workers of whichever replicas are alive.
+SELECT * FROM sync_jobs
+WHERE status = 'running' AND NOT distributed
+  AND (heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => sqlc.arg(stale_seconds)::int))
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListSyncJobs :many
+SELECT * FROM sync_jobs
+ORDER BY id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: SetSyncJobRange :exec
+UPDATE sync_jobs
+SET
+  end_page = $2,
+  total_pages = $3
+WHERE id = $1;
+
+-- name: CheckpointSyncJob :exec
+UPDATE sync_jobs
+SET
+  checkpoint_page = sqlc.arg(checkpoint_page),
+  processed_users = processed_users + sqlc.arg(processed_users),
+  failed_users = failed_users + sqlc.arg(failed_users),
+  skipped_users = skipped_users + sqlc.arg(skipped_users)
+WHERE id = sqlc.arg(id);
+
+-- name: CheckpointTargetedSyncJob :exec
+-- Marks every user of a targeted job up to checkpoint_username as committed.
+UPDATE sync_jobs
+SET
+  checkpoint_username = sqlc.arg(checkpoint_username),
+  processed_users = processed_users + sqlc.arg(processed_users),
+  failed_users = failed_users + sqlc.arg(failed_users)
+WHERE id = sqlc.arg(id);
+
+-- name: AddSyncJobProgress :exec
+-- Counts a page of a distributed job; its checkpoints live on the page leases.
+UPDATE sync_jobs
+SET
+  processed_users = processed_users + sqlc.arg(processed_users),
+  failed_users = failed_users + sqlc.arg(failed_users),
+  skipped_users = skipped_users + sqlc.arg(skipped_users)
+WHERE id = sqlc.arg(id);
+
+-- name: RecordSyncJobError :exec
+UPDATE sync_jobs
+SET
+  error_count = error_count + 1,
+  failed_pages = failed_pages + sqlc.arg(failed_pages),
+  last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: SetSyncJobStatus :exec
+UPDATE sync_jobs
+SET status = $2
+WHERE id = $1;
+
+-- name: FinishSyncJob :exec
+UPDATE sync_jobs
+SET
+  status = $2,
+  finished_at = NOW()
+WHERE id = $1;
+
+-- name: ClaimSyncJob :one
+-- Makes owner the replica running the job and reopens it if it was closed. A
+-- running or paused job is only taken over once its heartbeat is older than
+-- stale_seconds; otherwise, or for a finished job, no row is returned.
+UPDATE sync_jobs
+SET
+  owner = sqlc.arg(owner)::text,
+  heartbeat_at = NOW(),
+  status = 'running',
+  finished_at = NULL
+WHERE id = sqlc.arg(id)
+  AND status != 'finished'
+  AND (
+    status NOT IN ('running', 'paused')
+    OR heartbeat_at IS NULL
+    OR heartbeat_at < NOW() - make_interval(secs => sqlc.arg(stale_seconds)::int)
+  )
+RETURNING *;
+
+-- name: HeartbeatSyncJob :execrows
+-- Affects no row once another replica took the job over or it was closed.
+UPDATE sync_jobs
+SET heartbeat_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND owner = sqlc.arg(owner)::text
+  AND status IN ('running', 'paused');
+
+-- name: ReleaseSyncJob :exec
+-- Lets another replica take a job over right away, e.g. on shutdown.
+UPDATE sync_jobs
+SET heartbeat_at = NULL
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text;
@@ -0,0 +1,36 @@
+-- name: InsertSyncJobChanges :exec
+-- Appends changes a dry-run job found, one array element per change.
+INSERT INTO sync_job_changes (job_id, username, change, field, old_value, new_value)
+SELECT sqlc.arg(job_id), c.username, c.change, c.field, c.old_value, c.new_value
+FROM unnest(
+  sqlc.arg(usernames)::text[],
+  sqlc.arg(changes)::text[],
+  sqlc.arg(fields)::text[],
+  sqlc.arg(old_values)::text[],
+  sqlc.arg(new_values)::text[]
+) AS c(username, change, field, old_value, new_value);
+
+-- name: InsertSyncJobSeenUsers :exec
+INSERT INTO sync_job_seen_users (job_id, username)
+SELECT sqlc.arg(job_id), unnest(sqlc.arg(usernames)::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: InsertDisappearedUsers :execrows
+-- Ranked users we store that a dry-run sweep of the whole ranking never saw.
+INSERT INTO sync_job_changes (job_id, username, change, field, old_value)
+SELECT sqlc.arg(job_id), u.username, 'disappeared', 'global_ranking', u.global_ranking::text
+FROM user_data u
+WHERE u.global_ranking IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM sync_job_seen_users s
+    WHERE s.job_id = sqlc.arg(job_id) AND s.username = u.username
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM sync_job_changes c
+    WHERE c.job_id = sqlc.arg(job_id) AND c.username = u.username AND c.change = 'disappeared'
+  );
+
+-- name: ListSyncJobChanges :many
+SELECT * FROM sync_job_changes
+WHERE job_id = $1
+ORDER BY id;
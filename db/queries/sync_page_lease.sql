@@ -0,0 +1,103 @@
+-- name: CreatePageLeases :exec
+-- Splits start_page..end_page into leases of lease_pages pages. Leases that
+-- already exist are kept, so a resumed job does not lose their progress.
+INSERT INTO sync_page_leases (job_id, start_page, end_page, checkpoint_page)
+SELECT
+  sqlc.arg(job_id),
+  p,
+  LEAST(p + sqlc.arg(lease_pages)::int - 1, sqlc.arg(end_page)::int),
+  p - 1
+FROM generate_series(sqlc.arg(start_page)::int, sqlc.arg(end_page)::int, sqlc.arg(lease_pages)::int) AS p
+ON CONFLICT (job_id, start_page) DO NOTHING;
+
+-- name: ClaimPageLease :one
+-- Takes the first pending lease of a running job, or one whose owner stopped
+-- renewing it. Concurrent claimers skip each other's rows instead of waiting.
+UPDATE sync_page_leases
+SET
+  status = 'leased',
+  owner = sqlc.arg(owner)::text,
+  lease_expires_at = NOW() + make_interval(secs => sqlc.arg(ttl_seconds)::int),
+  attempts = attempts + 1
+WHERE id = (
+  SELECT l.id FROM sync_page_leases l
+  JOIN sync_jobs j ON j.id = l.job_id
+  WHERE j.status = 'running'
+    AND (l.status = 'pending' OR (l.status = 'leased' AND l.lease_expires_at < NOW()))
+  ORDER BY l.job_id, l.start_page
+  LIMIT 1
+  FOR UPDATE OF l SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RenewPageLease :execrows
+-- Affects no row once the lease was taken over or the job stopped running.
+UPDATE sync_page_leases l
+SET lease_expires_at = NOW() + make_interval(secs => sqlc.arg(ttl_seconds)::int)
+FROM sync_jobs j
+WHERE l.id = sqlc.arg(id)
+  AND l.owner = sqlc.arg(owner)::text
+  AND l.status = 'leased'
+  AND j.id = l.job_id
+  AND j.status = 'running';
+
+-- name: CheckpointPageLease :execrows
+UPDATE sync_page_leases
+SET checkpoint_page = sqlc.arg(checkpoint_page)
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'leased';
+
+-- name: CompletePageLease :execrows
+UPDATE sync_page_leases
+SET
+  status = 'done',
+  lease_expires_at = NULL
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'leased';
+
+-- name: ReleasePageLease :exec
+-- Hands the lease back without counting it as failed, e.g. on shutdown or pause.
+UPDATE sync_page_leases
+SET
+  status = 'pending',
+  owner = NULL,
+  lease_expires_at = NULL
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'leased';
+
+-- name: FailPageLease :exec
+-- Hands the lease back for another attempt, or gives up on it once it has
+-- been claimed max_attempts times.
+UPDATE sync_page_leases
+SET
+  status = CASE WHEN attempts >= sqlc.arg(max_attempts)::int THEN 'failed' ELSE 'pending' END,
+  owner = NULL,
+  lease_expires_at = NULL,
+  last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id) AND owner = sqlc.arg(owner)::text AND status = 'leased';
+
+-- name: ResetFailedPageLeases :exec
+UPDATE sync_page_leases
+SET
+  status = 'pending',
+  attempts = 0
+WHERE job_id = $1 AND status = 'failed';
+
+-- name: ListPageLeases :many
+SELECT * FROM sync_page_leases
+WHERE job_id = $1
+ORDER BY start_page;
+
+-- name: FinishLeasedSyncJob :execrows
+-- Closes a distributed job once none of its leases is pending or leased. Any
+-- replica may call it after closing a lease; only the last one matches.
+UPDATE sync_jobs j
+SET
+  status = CASE
+    WHEN EXISTS (SELECT 1 FROM sync_page_leases f WHERE f.job_id = j.id AND f.status = 'failed') THEN 'failed'
+    ELSE 'finished'
+  END,
+  finished_at = NOW()
+WHERE j.id = $1
+  AND j.status = 'running'
+  AND NOT EXISTS (
+    SELECT 1 FROM sync_page_leases o
+    WHERE o.job_id = j.id AND o.status IN ('pending', 'leased')
+  );
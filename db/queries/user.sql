@@ -1,18 +1,22 @@
 -- name: CreateUser :one
 INSERT INTO user_data (
   username, user_slug, user_avatar, country_code, country_name, real_name, typename,
-  total_problems_solved, total_submissions
+  total_problems_solved, total_submissions,
+  easy_solved, medium_solved, hard_solved,
+  easy_submissions, medium_submissions, hard_submissions, all_submissions
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 )
 RETURNING *;
 
 -- name: UpsertUser :one
 INSERT INTO user_data (
   username, user_slug, user_avatar, country_code, country_name, real_name, typename,
-  total_problems_solved, total_submissions
+  total_problems_solved, total_submissions,
+  easy_solved, medium_solved, hard_solved,
+  easy_submissions, medium_submissions, hard_submissions, all_submissions
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 )
 ON CONFLICT (username) DO UPDATE
 SET
@@ -23,7 +27,22 @@ SET
   real_name = EXCLUDED.real_name,
   typename = EXCLUDED.typename,
   total_problems_solved = EXCLUDED.total_problems_solved,
-  total_submissions = EXCLUDED.total_submissions
+  total_submissions = EXCLUDED.total_submissions,
+  easy_solved = EXCLUDED.easy_solved,
+  medium_solved = EXCLUDED.medium_solved,
+  hard_solved = EXCLUDED.hard_solved,
+  easy_submissions = EXCLUDED.easy_submissions,
+  medium_submissions = EXCLUDED.medium_submissions,
+  hard_submissions = EXCLUDED.hard_submissions,
+  all_submissions = EXCLUDED.all_submissions,
+  stats_fetched_at = NOW(),
+  account_status = 'active',
+  status_changed_at = CASE WHEN user_data.account_status = 'active' THEN user_data.status_changed_at ELSE NOW() END,
+  last_seen_at = NOW(),
+  missing_since = NULL,
+  miss_count = 0,
+  renamed_to = NULL,
+  last_missed_at = NULL
 RETURNING *;
 
 -- name: GetUserByUsername :one
@@ -33,7 +52,7 @@ LIMIT 1;
 
 -- name: ListUsers :many
 SELECT * FROM user_data
-WHERE country_code IS NOT NULL AND country_code != ''
+WHERE country_code IS NOT NULL AND country_code != '' AND account_status IN ('active', 'missing')
 ORDER BY total_problems_solved DESC, total_submissions ASC
 LIMIT $1 OFFSET $2;
 
@@ -41,9 +60,14 @@ LIMIT $1 OFFSET $2;
 SELECT *
 FROM user_data
 WHERE
-  (sqlc.arg(country)::text = 'all' AND country_code IS NOT NULL AND country_code != '')
-  OR (sqlc.arg(country)::text != 'all' AND country_code = sqlc.arg(country)::text)
+  account_status IN ('active', 'missing')
+  AND (
+    (sqlc.arg(country)::text = 'all' AND country_code IS NOT NULL AND country_code != '')
+    OR (sqlc.arg(country)::text != 'all' AND country_code = sqlc.arg(country)::text)
+  )
 ORDER BY
+  CASE WHEN sqlc.arg(order_by)::text = 'rating' THEN contest_rating END DESC NULLS LAST,
+  CASE WHEN sqlc.arg(order_by)::text = 'hard' THEN hard_solved END DESC,
   total_problems_solved DESC,
   total_submissions ASC,
   username ASC
@@ -71,6 +95,35 @@ WHERE username = $1;
 SELECT COUNT(*) 
 FROM user_data
 WHERE
-  (country_code = $1::text AND $1::text != 'all')
-  OR
-  ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '');
+  account_status IN ('active', 'missing')
+  AND (
+    (country_code = $1::text AND $1::text != 'all')
+    OR
+    ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '')
+  );
+
+-- name: GetUsersByUsernames :many
+SELECT * FROM user_data
+WHERE username = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: GetUsersFreshness :many
+SELECT username, contest_rating, stats_fetched_at
+FROM user_data
+WHERE username = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: ListUsernamesByCountry :many
+-- Stored usernames of a country after after_username, in username order.
+SELECT username FROM user_data
+WHERE country_code = sqlc.arg(country)::text AND username > sqlc.arg(after_username)::text
+  AND account_status IN ('active', 'missing')
+ORDER BY username
+LIMIT sqlc.arg(limit_count);
+
+-- name: ListUsernamesNotFetchedSince :many
+-- Usernames whose stats were not fetched since fetched_before, after after_username.
+SELECT username FROM user_data
+WHERE (stats_fetched_at IS NULL OR stats_fetched_at < sqlc.arg(fetched_before))
+  AND username > sqlc.arg(after_username)::text
+  AND account_status IN ('active', 'missing')
+ORDER BY username
+LIMIT sqlc.arg(limit_count);
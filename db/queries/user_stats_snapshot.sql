@@ -0,0 +1,18 @@
+-- name: GetUserStatsHistory :many
+-- Returns the last snapshot of every day/week/month bucket in [from_time, to_time).
+SELECT DISTINCT ON (bucket)
+  date_trunc(sqlc.arg(granularity)::text, captured_at)::timestamptz AS bucket,
+  total_problems_solved,
+  total_submissions,
+  easy_solved,
+  medium_solved,
+  hard_solved,
+  all_submissions,
+  contest_rating,
+  global_ranking,
+  captured_at
+FROM user_stats_snapshots
+WHERE username = sqlc.arg(username)
+  AND captured_at >= sqlc.arg(from_time)
+  AND captured_at < sqlc.arg(to_time)
+ORDER BY bucket ASC, captured_at DESC;
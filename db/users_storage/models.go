@@ -10,28 +10,214 @@ import (
 )
 
 type StagingUserDatum struct {
-	Username            string         `json:"username"`
-	UserSlug            string         `json:"user_slug"`
-	UserAvatar          sql.NullString `json:"user_avatar"`
-	CountryCode         sql.NullString `json:"country_code"`
-	CountryName         sql.NullString `json:"country_name"`
-	RealName            sql.NullString `json:"real_name"`
-	Typename            sql.NullString `json:"typename"`
-	TotalProblemsSolved int32          `json:"total_problems_solved"`
-	TotalSubmissions    int32          `json:"total_submissions"`
+	Username            string          `json:"username"`
+	UserSlug            string          `json:"user_slug"`
+	UserAvatar          sql.NullString  `json:"user_avatar"`
+	CountryCode         sql.NullString  `json:"country_code"`
+	CountryName         sql.NullString  `json:"country_name"`
+	RealName            sql.NullString  `json:"real_name"`
+	Typename            sql.NullString  `json:"typename"`
+	TotalProblemsSolved int32           `json:"total_problems_solved"`
+	TotalSubmissions    int32           `json:"total_submissions"`
+	ContestRating       sql.NullFloat64 `json:"contest_rating"`
+	GlobalRanking       sql.NullInt32   `json:"global_ranking"`
+	ContestRankings     sql.NullString  `json:"contest_rankings"`
+	DataRegion          sql.NullString  `json:"data_region"`
+	EasySolved          int32           `json:"easy_solved"`
+	MediumSolved        int32           `json:"medium_solved"`
+	HardSolved          int32           `json:"hard_solved"`
+	EasySubmissions     int32           `json:"easy_submissions"`
+	MediumSubmissions   int32           `json:"medium_submissions"`
+	HardSubmissions     int32           `json:"hard_submissions"`
+	AllSubmissions      int32           `json:"all_submissions"`
 }
 
 type UserDatum struct {
-	ID                  int32          `json:"id"`
-	Username            string         `json:"username"`
-	UserSlug            string         `json:"user_slug"`
-	UserAvatar          sql.NullString `json:"user_avatar"`
-	CountryCode         sql.NullString `json:"country_code"`
-	CountryName         sql.NullString `json:"country_name"`
-	RealName            sql.NullString `json:"real_name"`
-	Typename            sql.NullString `json:"typename"`
-	TotalProblemsSolved int32          `json:"total_problems_solved"`
-	TotalSubmissions    int32          `json:"total_submissions"`
-	CreatedAt           time.Time      `json:"created_at"`
+	ID                  int32           `json:"id"`
+	Username            string          `json:"username"`
+	UserSlug            string          `json:"user_slug"`
+	UserAvatar          sql.NullString  `json:"user_avatar"`
+	CountryCode         sql.NullString  `json:"country_code"`
+	CountryName         sql.NullString  `json:"country_name"`
+	RealName            sql.NullString  `json:"real_name"`
+	Typename            sql.NullString  `json:"typename"`
+	TotalProblemsSolved int32           `json:"total_problems_solved"`
+	TotalSubmissions    int32           `json:"total_submissions"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	ContestRating       sql.NullFloat64 `json:"contest_rating"`
+	GlobalRanking       sql.NullInt32   `json:"global_ranking"`
+	ContestRankings     sql.NullString  `json:"contest_rankings"`
+	DataRegion          sql.NullString  `json:"data_region"`
+	EasySolved          int32           `json:"easy_solved"`
+	MediumSolved        int32           `json:"medium_solved"`
+	HardSolved          int32           `json:"hard_solved"`
+	EasySubmissions     int32           `json:"easy_submissions"`
+	MediumSubmissions   int32           `json:"medium_submissions"`
+	HardSubmissions     int32           `json:"hard_submissions"`
+	AllSubmissions      int32           `json:"all_submissions"`
+	StatsFetchedAt      sql.NullTime    `json:"stats_fetched_at"`
+	AccountStatus       string          `json:"account_status"`
+	StatusChangedAt     time.Time       `json:"status_changed_at"`
+	LastSeenAt          sql.NullTime    `json:"last_seen_at"`
+	MissingSince        sql.NullTime    `json:"missing_since"`
+	MissCount           int32           `json:"miss_count"`
+	RenamedTo           sql.NullString  `json:"renamed_to"`
+	LastMissedAt        sql.NullTime    `json:"last_missed_at"`
+}
+
+type UserStatsSnapshot struct {
+	ID                  int64           `json:"id"`
+	Username            string          `json:"username"`
+	TotalProblemsSolved int32           `json:"total_problems_solved"`
+	TotalSubmissions    int32           `json:"total_submissions"`
+	EasySolved          int32           `json:"easy_solved"`
+	MediumSolved        int32           `json:"medium_solved"`
+	HardSolved          int32           `json:"hard_solved"`
+	AllSubmissions      int32           `json:"all_submissions"`
+	ContestRating       sql.NullFloat64 `json:"contest_rating"`
+	GlobalRanking       sql.NullInt32   `json:"global_ranking"`
+	CapturedAt          time.Time       `json:"captured_at"`
+}
+
+type SyncJob struct {
+	ID                  int64          `json:"id"`
+	Status              string         `json:"status"`
+	StartPage           int32          `json:"start_page"`
+	EndPage             int32          `json:"end_page"`
+	TotalPages          int32          `json:"total_pages"`
+	CheckpointPage      int32          `json:"checkpoint_page"`
+	Workers             int32          `json:"workers"`
+	BatchSize           int32          `json:"batch_size"`
+	DelayMs             int32          `json:"delay_ms"`
+	ProcessedUsers      int32          `json:"processed_users"`
+	FailedUsers         int32          `json:"failed_users"`
+	FailedPages         int32          `json:"failed_pages"`
+	ErrorCount          int32          `json:"error_count"`
+	LastError           sql.NullString `json:"last_error"`
+	StartedAt           time.Time      `json:"started_at"`
+	FinishedAt          sql.NullTime   `json:"finished_at"`
 	UpdatedAt           time.Time      `json:"updated_at"`
+	Mode                string         `json:"mode"`
+	MaxAgeSeconds       int32          `json:"max_age_seconds"`
+	SkippedUsers        int32          `json:"skipped_users"`
+	Distributed         bool           `json:"distributed"`
+	LeasePages          int32          `json:"lease_pages"`
+	Owner               sql.NullString `json:"owner"`
+	HeartbeatAt         sql.NullTime   `json:"heartbeat_at"`
+	Target              string         `json:"target"`
+	TargetUsernames     []string       `json:"target_usernames"`
+	TargetCountry       string         `json:"target_country"`
+	TargetUpdatedBefore sql.NullTime   `json:"target_updated_before"`
+	CheckpointUsername  string         `json:"checkpoint_username"`
+	DryRun              bool           `json:"dry_run"`
+}
+
+type FailedUserFetch struct {
+	Username      string         `json:"username"`
+	Page          sql.NullInt32  `json:"page"`
+	JobID         sql.NullInt64  `json:"job_id"`
+	ErrorClass    string         `json:"error_class"`
+	Attempts      int32          `json:"attempts"`
+	LastError     sql.NullString `json:"last_error"`
+	Status        string         `json:"status"`
+	FirstFailedAt time.Time      `json:"first_failed_at"`
+	LastAttemptAt time.Time      `json:"last_attempt_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type SyncSchedule struct {
+	Name      string    `json:"name"`
+	Spec      string    `json:"spec"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ScheduleRun struct {
+	ID           int64          `json:"id"`
+	ScheduleName string         `json:"schedule_name"`
+	Status       string         `json:"status"`
+	Error        sql.NullString `json:"error"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   sql.NullTime   `json:"finished_at"`
+	Owner        string         `json:"owner"`
+	HeartbeatAt  sql.NullTime   `json:"heartbeat_at"`
+	ScheduledAt  sql.NullTime   `json:"scheduled_at"`
+}
+
+type TrackedGroup struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TrackedGroupMember struct {
+	GroupID  int64     `json:"group_id"`
+	Username string    `json:"username"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+type UserRefreshTier struct {
+	Username  string    `json:"username"`
+	Tier      string    `json:"tier"`
+	Manual    bool      `json:"manual"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncPageLease struct {
+	ID             int64          `json:"id"`
+	JobID          int64          `json:"job_id"`
+	StartPage      int32          `json:"start_page"`
+	EndPage        int32          `json:"end_page"`
+	CheckpointPage int32          `json:"checkpoint_page"`
+	Status         string         `json:"status"`
+	Owner          sql.NullString `json:"owner"`
+	LeaseExpiresAt sql.NullTime   `json:"lease_expires_at"`
+	Attempts       int32          `json:"attempts"`
+	LastError      sql.NullString `json:"last_error"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+type SyncJobChange struct {
+	ID        int64     `json:"id"`
+	JobID     int64     `json:"job_id"`
+	Username  string    `json:"username"`
+	Change    string    `json:"change"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SyncJobSeenUser struct {
+	JobID    int64  `json:"job_id"`
+	Username string `json:"username"`
+}
+
+type UserChangeEvent struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EventOutbox struct {
+	ID             int64          `json:"id"`
+	EventID        int64          `json:"event_id"`
+	Username       string         `json:"username"`
+	Status         string         `json:"status"`
+	Attempts       int32          `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	Owner          string         `json:"owner"`
+	LeaseExpiresAt sql.NullTime   `json:"lease_expires_at"`
+	LastError      sql.NullString `json:"last_error"`
+	DeliveredAt    sql.NullTime   `json:"delivered_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
 }
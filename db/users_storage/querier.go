@@ -6,15 +6,148 @@ package users_storage
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
+	// Counts a page of a distributed job; its checkpoints live on the page leases.
+	AddSyncJobProgress(ctx context.Context, arg AddSyncJobProgressParams) error
+	AddTrackedGroupMembers(ctx context.Context, arg AddTrackedGroupMembersParams) error
+	CheckpointPageLease(ctx context.Context, arg CheckpointPageLeaseParams) (int64, error)
+	CheckpointSyncJob(ctx context.Context, arg CheckpointSyncJobParams) error
+	// Marks every user of a targeted job up to checkpoint_username as committed.
+	CheckpointTargetedSyncJob(ctx context.Context, arg CheckpointTargetedSyncJobParams) error
+	// Leases up to limit_count due events, oldest first. An event is only due once
+	// every earlier event of its user is delivered or dead, so a user's events are
+	// handed out one at a time and in order. Concurrent claimers skip each other's
+	// rows instead of waiting; a lease that expires makes the event due again.
+	ClaimOutboxEvents(ctx context.Context, arg ClaimOutboxEventsParams) ([]ClaimOutboxEventsRow, error)
+	// Takes the first pending lease of a running job, or one whose owner stopped
+	// renewing it. Concurrent claimers skip each other's rows instead of waiting.
+	ClaimPageLease(ctx context.Context, arg ClaimPageLeaseParams) (SyncPageLease, error)
+	// Claims the run of a schedule due at scheduled_at for owner. Every replica
+	// fires the same schedule at the same time and only the first claim gets a
+	// row; the others get none and leave the run to it. While a run of the
+	// schedule is still going on a replica with a fresh heartbeat, the claimed run
+	// is recorded as skipped and finished right away.
+	ClaimScheduleRun(ctx context.Context, arg ClaimScheduleRunParams) (ScheduleRun, error)
+	// Makes owner the replica running the job and reopens it if it was closed. A
+	// running or paused job is only taken over once its heartbeat is older than
+	// stale_seconds; otherwise, or for a finished job, no row is returned.
+	ClaimSyncJob(ctx context.Context, arg ClaimSyncJobParams) (SyncJob, error)
+	CompletePageLease(ctx context.Context, arg CompletePageLeaseParams) (int64, error)
+	CountFailedUserFetches(ctx context.Context, status string) (int64, error)
+	CountUsersByAccountStatus(ctx context.Context, accountStatus string) (int64, error)
+	// Splits start_page..end_page into leases of lease_pages pages. Leases that
+	// already exist are kept, so a resumed job does not lose their progress.
+	CreatePageLeases(ctx context.Context, arg CreatePageLeasesParams) error
+	CreateSyncJob(ctx context.Context, arg CreateSyncJobParams) (SyncJob, error)
+	CreateTrackedGroup(ctx context.Context, name string) (TrackedGroup, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (UserDatum, error)
+	// Deletes the change events created before created_before, together with
+	// their delivered or dead outbox rows. Events still pending delivery are kept.
+	DeleteExpiredChangeEvents(ctx context.Context, createdBefore time.Time) (int64, error)
+	DeleteFailedUserFetches(ctx context.Context, usernames []string) error
+	DeleteTrackedGroup(ctx context.Context, id int64) error
 	DeleteUserByUsername(ctx context.Context, username string) error
+	EnsureSyncSchedule(ctx context.Context, arg EnsureSyncScheduleParams) error
+	// Hands the lease back for another attempt, or gives up on it once it has
+	// been claimed max_attempts times.
+	FailPageLease(ctx context.Context, arg FailPageLeaseParams) error
+	// Fails the runs whose replica stopped sending heartbeats for stale_seconds,
+	// i.e. stopped or died while executing them.
+	FailStaleScheduleRuns(ctx context.Context, staleSeconds int32) (int64, error)
+	// Closes a distributed job once none of its leases is pending or leased. Any
+	// replica may call it after closing a lease; only the last one matches.
+	FinishLeasedSyncJob(ctx context.Context, id int64) (int64, error)
+	FinishScheduleRun(ctx context.Context, arg FinishScheduleRunParams) error
+	FinishSyncJob(ctx context.Context, arg FinishSyncJobParams) error
 	GetAllUsersCountByCountry(ctx context.Context, dollar_1 string) (int64, error)
+	// The newest running job whose owner has not sent a heartbeat for
+	// stale_seconds, i.e. stopped or died. Distributed jobs are not resumed here:
+	// their leases are picked up by the lease workers of whichever replicas are alive.
+	GetInterruptedSyncJob(ctx context.Context, staleSeconds int32) (SyncJob, error)
+	GetLatestSyncJob(ctx context.Context) (SyncJob, error)
+	GetSyncJob(ctx context.Context, id int64) (SyncJob, error)
+	GetSyncSchedule(ctx context.Context, name string) (SyncSchedule, error)
+	GetTrackedGroupByName(ctx context.Context, name string) (TrackedGroup, error)
 	GetUserByUsername(ctx context.Context, username string) (UserDatum, error)
+	// Returns the last snapshot of every day/week/month bucket in [from_time, to_time).
+	GetUserStatsHistory(ctx context.Context, arg GetUserStatsHistoryParams) ([]GetUserStatsHistoryRow, error)
 	GetUsersByCountry(ctx context.Context, arg GetUsersByCountryParams) ([]UserDatum, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]UserDatum, error)
+	GetUsersFreshness(ctx context.Context, usernames []string) ([]GetUsersFreshnessRow, error)
+	HeartbeatScheduleRuns(ctx context.Context, owner string) error
+	// Affects no row once another replica took the job over or it was closed.
+	HeartbeatSyncJob(ctx context.Context, arg HeartbeatSyncJobParams) (int64, error)
+	// Ranked users we store that a dry-run sweep of the whole ranking never saw.
+	InsertDisappearedUsers(ctx context.Context, jobID int64) (int64, error)
+	// Appends changes a dry-run job found, one array element per change.
+	InsertSyncJobChanges(ctx context.Context, arg InsertSyncJobChangesParams) error
+	InsertSyncJobSeenUsers(ctx context.Context, arg InsertSyncJobSeenUsersParams) error
+	// Marks listed missing or deleted users as renamed when exactly one active
+	// account created after them has the same custom (non-default) avatar, or the
+	// same real name, country and avatar. Ambiguous matches are left alone.
+	LinkRenamedUsers(ctx context.Context, usernames []string) ([]LinkRenamedUsersRow, error)
+	ListFailedUserFetches(ctx context.Context, arg ListFailedUserFetchesParams) ([]FailedUserFetch, error)
+	ListLatestScheduleRuns(ctx context.Context) ([]ScheduleRun, error)
+	// Permanently failed users LeetCode said do not exist whose account is still
+	// missing and whose last miss is miss_interval_seconds old. The retry pass
+	// fetches them again so every miss is counted until the deletion is confirmed
+	// or the user comes back.
+	ListMissingUsersToRecheck(ctx context.Context, arg ListMissingUsersToRecheckParams) ([]string, error)
+	ListOverdueLongTailUsers(ctx context.Context, arg ListOverdueLongTailUsersParams) ([]ListOverdueLongTailUsersRow, error)
+	// Users of a tier not refreshed since refreshed_before, never fetched users first.
+	// Permanently failed users are left to the dead-letter queue.
+	ListOverdueUsersByTier(ctx context.Context, arg ListOverdueUsersByTierParams) ([]ListOverdueUsersByTierRow, error)
+	ListPageLeases(ctx context.Context, jobID int64) ([]SyncPageLease, error)
+	ListScheduleRuns(ctx context.Context, arg ListScheduleRunsParams) ([]ScheduleRun, error)
+	ListSyncJobChanges(ctx context.Context, jobID int64) ([]SyncJobChange, error)
+	ListSyncJobs(ctx context.Context, arg ListSyncJobsParams) ([]SyncJob, error)
+	ListSyncSchedules(ctx context.Context) ([]SyncSchedule, error)
+	ListTrackedGroupMembers(ctx context.Context, groupID int64) ([]string, error)
+	ListTrackedGroups(ctx context.Context) ([]ListTrackedGroupsRow, error)
+	// Stored usernames of a country after after_username, in username order.
+	ListUsernamesByCountry(ctx context.Context, arg ListUsernamesByCountryParams) ([]string, error)
+	// Usernames whose stats were not fetched since fetched_before, after after_username.
+	ListUsernamesNotFetchedSince(ctx context.Context, arg ListUsernamesNotFetchedSinceParams) ([]string, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]UserDatum, error)
+	ListUsersByAccountStatus(ctx context.Context, arg ListUsersByAccountStatusParams) ([]ListUsersByAccountStatusRow, error)
+	// Affects no row once the lease was taken over by another dispatcher.
+	MarkOutboxEventDelivered(ctx context.Context, arg MarkOutboxEventDeliveredParams) (int64, error)
+	MarkUserManual(ctx context.Context, username string) error
+	// Counts a miss for every listed user LeetCode reported as not existing, at most
+	// one per miss_interval_seconds. The misses_to_delete-th miss in a row confirms
+	// the account as deleted.
+	MarkUsersMissing(ctx context.Context, arg MarkUsersMissingParams) ([]MarkUsersMissingRow, error)
+	// Moves new tracked group members to the tracked tier right away; manual users
+	// keep theirs.
+	MarkUsersTracked(ctx context.Context, usernames []string) error
+	// Manual users keep their tier. Everyone else becomes tracked (member of any
+	// tracked group), top_n (among the top_n solvers of their country) or drops
+	// back to the long tail.
+	RecomputeRefreshTiers(ctx context.Context, topN int32) error
+	// Bumps the attempt counter and gives up on the user once max_attempts is
+	// reached. A user LeetCode says does not exist is recorded with max_attempts 1,
+	// so it is permanent at once; its misses are counted on the account instead.
+	RecordFailedUserFetch(ctx context.Context, arg RecordFailedUserFetchParams) (FailedUserFetch, error)
+	RecordSyncJobError(ctx context.Context, arg RecordSyncJobErrorParams) error
+	// Hands the lease back without counting it as failed, e.g. on shutdown or pause.
+	ReleasePageLease(ctx context.Context, arg ReleasePageLeaseParams) error
+	// Lets another replica take a job over right away, e.g. on shutdown.
+	ReleaseSyncJob(ctx context.Context, arg ReleaseSyncJobParams) error
+	RemoveTrackedGroupMember(ctx context.Context, arg RemoveTrackedGroupMemberParams) error
+	// Affects no row once the lease was taken over or the job stopped running.
+	RenewPageLease(ctx context.Context, arg RenewPageLeaseParams) (int64, error)
+	ResetFailedPageLeases(ctx context.Context, jobID int64) error
+	// Schedules another attempt after retry_after_seconds, or gives up on the
+	// event once it has been claimed max_attempts times. A dead event no longer
+	// holds back the later events of its user. Returns no row once the lease was
+	// taken over by another dispatcher.
+	RetryOutboxEvent(ctx context.Context, arg RetryOutboxEventParams) (string, error)
+	SetSyncJobRange(ctx context.Context, arg SetSyncJobRangeParams) error
+	SetSyncJobStatus(ctx context.Context, arg SetSyncJobStatusParams) error
+	UpdateSyncSchedule(ctx context.Context, arg UpdateSyncScheduleParams) (SyncSchedule, error)
 	UpdateUserByUsername(ctx context.Context, arg UpdateUserByUsernameParams) (UserDatum, error)
 	UpsertUser(ctx context.Context, arg UpsertUserParams) (UserDatum, error)
 }
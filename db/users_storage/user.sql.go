@@ -8,16 +8,20 @@ package users_storage
 import (
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO user_data (
   username, user_slug, user_avatar, country_code, country_name, real_name, typename,
-  total_problems_solved, total_submissions
+  total_problems_solved, total_submissions,
+  easy_solved, medium_solved, hard_solved,
+  easy_submissions, medium_submissions, hard_submissions, all_submissions
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 )
-RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at
+RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at
 `
 
 type CreateUserParams struct {
@@ -30,6 +34,13 @@ type CreateUserParams struct {
 	Typename            sql.NullString `json:"typename"`
 	TotalProblemsSolved int32          `json:"total_problems_solved"`
 	TotalSubmissions    int32          `json:"total_submissions"`
+	EasySolved          int32          `json:"easy_solved"`
+	MediumSolved        int32          `json:"medium_solved"`
+	HardSolved          int32          `json:"hard_solved"`
+	EasySubmissions     int32          `json:"easy_submissions"`
+	MediumSubmissions   int32          `json:"medium_submissions"`
+	HardSubmissions     int32          `json:"hard_submissions"`
+	AllSubmissions      int32          `json:"all_submissions"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserDatum, error) {
@@ -43,6 +54,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserDat
 		arg.Typename,
 		arg.TotalProblemsSolved,
 		arg.TotalSubmissions,
+		arg.EasySolved,
+		arg.MediumSolved,
+		arg.HardSolved,
+		arg.EasySubmissions,
+		arg.MediumSubmissions,
+		arg.HardSubmissions,
+		arg.AllSubmissions,
 	)
 	var i UserDatum
 	err := row.Scan(
@@ -58,6 +76,25 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserDat
 		&i.TotalSubmissions,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContestRating,
+		&i.GlobalRanking,
+		&i.ContestRankings,
+		&i.DataRegion,
+		&i.EasySolved,
+		&i.MediumSolved,
+		&i.HardSolved,
+		&i.EasySubmissions,
+		&i.MediumSubmissions,
+		&i.HardSubmissions,
+		&i.AllSubmissions,
+		&i.StatsFetchedAt,
+		&i.AccountStatus,
+		&i.StatusChangedAt,
+		&i.LastSeenAt,
+		&i.MissingSince,
+		&i.MissCount,
+		&i.RenamedTo,
+		&i.LastMissedAt,
 	)
 	return i, err
 }
@@ -76,9 +113,12 @@ const getAllUsersCountByCountry = `-- name: GetAllUsersCountByCountry :one
 SELECT COUNT(*) 
 FROM user_data
 WHERE
-  (country_code = $1::text AND $1::text != 'all')
-  OR
-  ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '')
+  account_status IN ('active', 'missing')
+  AND (
+    (country_code = $1::text AND $1::text != 'all')
+    OR
+    ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '')
+  )
 `
 
 func (q *Queries) GetAllUsersCountByCountry(ctx context.Context, dollar_1 string) (int64, error) {
@@ -89,7 +129,7 @@ func (q *Queries) GetAllUsersCountByCountry(ctx context.Context, dollar_1 string
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at FROM user_data
+SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at FROM user_data
 WHERE username = $1
 LIMIT 1
 `
@@ -110,31 +150,61 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (UserD
 		&i.TotalSubmissions,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContestRating,
+		&i.GlobalRanking,
+		&i.ContestRankings,
+		&i.DataRegion,
+		&i.EasySolved,
+		&i.MediumSolved,
+		&i.HardSolved,
+		&i.EasySubmissions,
+		&i.MediumSubmissions,
+		&i.HardSubmissions,
+		&i.AllSubmissions,
+		&i.StatsFetchedAt,
+		&i.AccountStatus,
+		&i.StatusChangedAt,
+		&i.LastSeenAt,
+		&i.MissingSince,
+		&i.MissCount,
+		&i.RenamedTo,
+		&i.LastMissedAt,
 	)
 	return i, err
 }
 
 const getUsersByCountry = `-- name: GetUsersByCountry :many
-SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at
+SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at
 FROM user_data
 WHERE
-  ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '')
-  OR ($1::text != 'all' AND country_code = $1::text)
+  account_status IN ('active', 'missing')
+  AND (
+    ($1::text = 'all' AND country_code IS NOT NULL AND country_code != '')
+    OR ($1::text != 'all' AND country_code = $1::text)
+  )
 ORDER BY
+  CASE WHEN $2::text = 'rating' THEN contest_rating END DESC NULLS LAST,
+  CASE WHEN $2::text = 'hard' THEN hard_solved END DESC,
   total_problems_solved DESC,
   total_submissions ASC,
   username ASC
-LIMIT $3 OFFSET $2
+LIMIT $4 OFFSET $3
 `
 
 type GetUsersByCountryParams struct {
 	Country   string `json:"country"`
+	OrderBy   string `json:"order_by"`
 	OffsetArg int32  `json:"offset_arg"`
 	LimitArg  int32  `json:"limit_arg"`
 }
 
 func (q *Queries) GetUsersByCountry(ctx context.Context, arg GetUsersByCountryParams) ([]UserDatum, error) {
-	rows, err := q.db.QueryContext(ctx, getUsersByCountry, arg.Country, arg.OffsetArg, arg.LimitArg)
+	rows, err := q.db.QueryContext(ctx, getUsersByCountry,
+		arg.Country,
+		arg.OrderBy,
+		arg.OffsetArg,
+		arg.LimitArg,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -155,6 +225,25 @@ func (q *Queries) GetUsersByCountry(ctx context.Context, arg GetUsersByCountryPa
 			&i.TotalSubmissions,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ContestRating,
+			&i.GlobalRanking,
+			&i.ContestRankings,
+			&i.DataRegion,
+			&i.EasySolved,
+			&i.MediumSolved,
+			&i.HardSolved,
+			&i.EasySubmissions,
+			&i.MediumSubmissions,
+			&i.HardSubmissions,
+			&i.AllSubmissions,
+			&i.StatsFetchedAt,
+			&i.AccountStatus,
+			&i.StatusChangedAt,
+			&i.LastSeenAt,
+			&i.MissingSince,
+			&i.MissCount,
+			&i.RenamedTo,
+			&i.LastMissedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -169,9 +258,189 @@ func (q *Queries) GetUsersByCountry(ctx context.Context, arg GetUsersByCountryPa
 	return items, nil
 }
 
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at FROM user_data
+WHERE username = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]UserDatum, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserDatum{}
+	for rows.Next() {
+		var i UserDatum
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.UserSlug,
+			&i.UserAvatar,
+			&i.CountryCode,
+			&i.CountryName,
+			&i.RealName,
+			&i.Typename,
+			&i.TotalProblemsSolved,
+			&i.TotalSubmissions,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ContestRating,
+			&i.GlobalRanking,
+			&i.ContestRankings,
+			&i.DataRegion,
+			&i.EasySolved,
+			&i.MediumSolved,
+			&i.HardSolved,
+			&i.EasySubmissions,
+			&i.MediumSubmissions,
+			&i.HardSubmissions,
+			&i.AllSubmissions,
+			&i.StatsFetchedAt,
+			&i.AccountStatus,
+			&i.StatusChangedAt,
+			&i.LastSeenAt,
+			&i.MissingSince,
+			&i.MissCount,
+			&i.RenamedTo,
+			&i.LastMissedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersFreshness = `-- name: GetUsersFreshness :many
+SELECT username, contest_rating, stats_fetched_at
+FROM user_data
+WHERE username = ANY($1::text[])
+`
+
+type GetUsersFreshnessRow struct {
+	Username       string          `json:"username"`
+	ContestRating  sql.NullFloat64 `json:"contest_rating"`
+	StatsFetchedAt sql.NullTime    `json:"stats_fetched_at"`
+}
+
+func (q *Queries) GetUsersFreshness(ctx context.Context, usernames []string) ([]GetUsersFreshnessRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersFreshness, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUsersFreshnessRow{}
+	for rows.Next() {
+		var i GetUsersFreshnessRow
+		if err := rows.Scan(&i.Username, &i.ContestRating, &i.StatsFetchedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsernamesByCountry = `-- name: ListUsernamesByCountry :many
+SELECT username FROM user_data
+WHERE country_code = $1::text AND username > $2::text
+  AND account_status IN ('active', 'missing')
+ORDER BY username
+LIMIT $3
+`
+
+type ListUsernamesByCountryParams struct {
+	Country       string `json:"country"`
+	AfterUsername string `json:"after_username"`
+	LimitCount    int32  `json:"limit_count"`
+}
+
+// Stored usernames of a country after after_username, in username order.
+func (q *Queries) ListUsernamesByCountry(ctx context.Context, arg ListUsernamesByCountryParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listUsernamesByCountry,
+		arg.Country,
+		arg.AfterUsername,
+		arg.LimitCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		items = append(items, username)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsernamesNotFetchedSince = `-- name: ListUsernamesNotFetchedSince :many
+SELECT username FROM user_data
+WHERE (stats_fetched_at IS NULL OR stats_fetched_at < $1)
+  AND username > $2::text
+  AND account_status IN ('active', 'missing')
+ORDER BY username
+LIMIT $3
+`
+
+type ListUsernamesNotFetchedSinceParams struct {
+	FetchedBefore sql.NullTime `json:"fetched_before"`
+	AfterUsername string       `json:"after_username"`
+	LimitCount    int32        `json:"limit_count"`
+}
+
+// Usernames whose stats were not fetched since fetched_before, after after_username.
+func (q *Queries) ListUsernamesNotFetchedSince(ctx context.Context, arg ListUsernamesNotFetchedSinceParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listUsernamesNotFetchedSince,
+		arg.FetchedBefore,
+		arg.AfterUsername,
+		arg.LimitCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		items = append(items, username)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at FROM user_data
-WHERE country_code IS NOT NULL AND country_code != ''
+SELECT id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at FROM user_data
+WHERE country_code IS NOT NULL AND country_code != '' AND account_status IN ('active', 'missing')
 ORDER BY total_problems_solved DESC, total_submissions ASC
 LIMIT $1 OFFSET $2
 `
@@ -203,6 +472,25 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]UserDat
 			&i.TotalSubmissions,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ContestRating,
+			&i.GlobalRanking,
+			&i.ContestRankings,
+			&i.DataRegion,
+			&i.EasySolved,
+			&i.MediumSolved,
+			&i.HardSolved,
+			&i.EasySubmissions,
+			&i.MediumSubmissions,
+			&i.HardSubmissions,
+			&i.AllSubmissions,
+			&i.StatsFetchedAt,
+			&i.AccountStatus,
+			&i.StatusChangedAt,
+			&i.LastSeenAt,
+			&i.MissingSince,
+			&i.MissCount,
+			&i.RenamedTo,
+			&i.LastMissedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -229,7 +517,7 @@ SET
   total_problems_solved = COALESCE($8, total_problems_solved),
   total_submissions = COALESCE($9, total_submissions)
 WHERE username = $1
-RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at
+RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at
 `
 
 type UpdateUserByUsernameParams struct {
@@ -270,6 +558,25 @@ func (q *Queries) UpdateUserByUsername(ctx context.Context, arg UpdateUserByUser
 		&i.TotalSubmissions,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContestRating,
+		&i.GlobalRanking,
+		&i.ContestRankings,
+		&i.DataRegion,
+		&i.EasySolved,
+		&i.MediumSolved,
+		&i.HardSolved,
+		&i.EasySubmissions,
+		&i.MediumSubmissions,
+		&i.HardSubmissions,
+		&i.AllSubmissions,
+		&i.StatsFetchedAt,
+		&i.AccountStatus,
+		&i.StatusChangedAt,
+		&i.LastSeenAt,
+		&i.MissingSince,
+		&i.MissCount,
+		&i.RenamedTo,
+		&i.LastMissedAt,
 	)
 	return i, err
 }
@@ -277,9 +584,11 @@ func (q *Queries) UpdateUserByUsername(ctx context.Context, arg UpdateUserByUser
 const upsertUser = `-- name: UpsertUser :one
 INSERT INTO user_data (
   username, user_slug, user_avatar, country_code, country_name, real_name, typename,
-  total_problems_solved, total_submissions
+  total_problems_solved, total_submissions,
+  easy_solved, medium_solved, hard_solved,
+  easy_submissions, medium_submissions, hard_submissions, all_submissions
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 )
 ON CONFLICT (username) DO UPDATE
 SET
@@ -290,8 +599,23 @@ SET
   real_name = EXCLUDED.real_name,
   typename = EXCLUDED.typename,
   total_problems_solved = EXCLUDED.total_problems_solved,
-  total_submissions = EXCLUDED.total_submissions
-RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at
+  total_submissions = EXCLUDED.total_submissions,
+  easy_solved = EXCLUDED.easy_solved,
+  medium_solved = EXCLUDED.medium_solved,
+  hard_solved = EXCLUDED.hard_solved,
+  easy_submissions = EXCLUDED.easy_submissions,
+  medium_submissions = EXCLUDED.medium_submissions,
+  hard_submissions = EXCLUDED.hard_submissions,
+  all_submissions = EXCLUDED.all_submissions,
+  stats_fetched_at = NOW(),
+  account_status = 'active',
+  status_changed_at = CASE WHEN user_data.account_status = 'active' THEN user_data.status_changed_at ELSE NOW() END,
+  last_seen_at = NOW(),
+  missing_since = NULL,
+  miss_count = 0,
+  renamed_to = NULL,
+  last_missed_at = NULL
+RETURNING id, username, user_slug, user_avatar, country_code, country_name, real_name, typename, total_problems_solved, total_submissions, created_at, updated_at, contest_rating, global_ranking, contest_rankings, data_region, easy_solved, medium_solved, hard_solved, easy_submissions, medium_submissions, hard_submissions, all_submissions, stats_fetched_at, account_status, status_changed_at, last_seen_at, missing_since, miss_count, renamed_to, last_missed_at
 `
 
 type UpsertUserParams struct {
@@ -304,6 +628,13 @@ type UpsertUserParams struct {
 	Typename            sql.NullString `json:"typename"`
 	TotalProblemsSolved int32          `json:"total_problems_solved"`
 	TotalSubmissions    int32          `json:"total_submissions"`
+	EasySolved          int32          `json:"easy_solved"`
+	MediumSolved        int32          `json:"medium_solved"`
+	HardSolved          int32          `json:"hard_solved"`
+	EasySubmissions     int32          `json:"easy_submissions"`
+	MediumSubmissions   int32          `json:"medium_submissions"`
+	HardSubmissions     int32          `json:"hard_submissions"`
+	AllSubmissions      int32          `json:"all_submissions"`
 }
 
 func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (UserDatum, error) {
@@ -317,6 +648,13 @@ func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (UserDat
 		arg.Typename,
 		arg.TotalProblemsSolved,
 		arg.TotalSubmissions,
+		arg.EasySolved,
+		arg.MediumSolved,
+		arg.HardSolved,
+		arg.EasySubmissions,
+		arg.MediumSubmissions,
+		arg.HardSubmissions,
+		arg.AllSubmissions,
 	)
 	var i UserDatum
 	err := row.Scan(
@@ -332,6 +670,25 @@ func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (UserDat
 		&i.TotalSubmissions,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ContestRating,
+		&i.GlobalRanking,
+		&i.ContestRankings,
+		&i.DataRegion,
+		&i.EasySolved,
+		&i.MediumSolved,
+		&i.HardSolved,
+		&i.EasySubmissions,
+		&i.MediumSubmissions,
+		&i.HardSubmissions,
+		&i.AllSubmissions,
+		&i.StatsFetchedAt,
+		&i.AccountStatus,
+		&i.StatusChangedAt,
+		&i.LastSeenAt,
+		&i.MissingSince,
+		&i.MissCount,
+		&i.RenamedTo,
+		&i.LastMissedAt,
 	)
 	return i, err
 }
@@ -24,6 +24,69 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/accounts": {
+            "get": {
+                "description": "Returns stored users in one account lifecycle state. A user LeetCode stops returning goes missing, is deleted after the configured number of misses in a row, or is linked to the account that took over its slug or avatar as renamed. Only active and missing users are ranked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts"
+                ],
+                "summary": "List users by account status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (1-based)",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1–100)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "active, missing (default), renamed or deleted",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Users",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListAccountsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/add-user": {
             "post": {
                 "description": "Takes a username, scrapes public data from LeetCode, and stores it in Postgres.",
@@ -81,13 +144,139 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "503": {
+                        "description": "LeetCode unavailable or throttling",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/failed-users": {
+            "get": {
+                "description": "Returns the dead-letter queue of users whose profile could not be fetched, with the ranking page, error class, attempt count and last error.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "failed-users"
+                ],
+                "summary": "List failed user fetches",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (1-based)",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1–100)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending (default) or permanent",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Failed users",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListFailedUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/failed-users/retry": {
+            "post": {
+                "description": "Starts a background pass that re-fetches pending users from the dead-letter queue. Users that keep failing are marked permanent after the configured number of attempts; users LeetCode says do not exist are permanent at once. The pass also re-checks those whose account is still missing, each miss counting towards deleting the account.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "failed-users"
+                ],
+                "summary": "Retry failed user fetches",
+                "parameters": [
+                    {
+                        "description": "Maximum number of users to retry",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.RetryFailedUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Retry started",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "A retry pass is already running",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
         "/api/v1/get-users": {
             "get": {
-                "description": "Returns users filtered by 2-letter country code, ordered by total_problems_solved DESC, then total_submissions ASC, then username ASC.",
+                "description": "Returns users filtered by 2-letter country code, ordered by total_problems_solved DESC, then total_submissions ASC, then username ASC.\nWith order_by=rating users are ranked by contest rating first; users without a rating come last.\nWith order_by=hard users are ranked by hard problems solved first.",
                 "consumes": [
                     "application/json"
                 ],
@@ -119,6 +308,17 @@ const docTemplate = `{
                         "name": "limit",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "solved",
+                            "rating",
+                            "hard"
+                        ],
+                        "type": "string",
+                        "description": "Ranking order: solved (default), rating or hard",
+                        "name": "order_by",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -149,9 +349,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stop-syncing": {
-            "post": {
-                "description": "Stops the ongoing background leaderboard sync job.",
+        "/api/v1/schedules": {
+            "get": {
+                "description": "Returns the recurring jobs (full_sync, priority_refresh, failed_users) with their cron spec, next run and last run outcome.",
                 "consumes": [
                     "application/json"
                 ],
@@ -159,21 +359,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "leaderboard"
+                    "schedules"
                 ],
-                "summary": "Stop leaderboard syncing",
+                "summary": "List schedules",
                 "responses": {
                     "200": {
-                        "description": "Syncing stopped",
+                        "description": "Schedules",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ScheduleResponse"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -184,9 +384,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/sync-leaderboard": {
-            "post": {
-                "description": "Starts the background process to sync the leaderboard from LeetCode.",
+        "/api/v1/schedules/{name}": {
+            "put": {
+                "description": "Changes the cron spec (standard 5-field or @descriptor such as @hourly, @every 15m; @every fires on multiples of its interval) and/or enables or disables a schedule. The change is persisted and applied immediately, and reaches the other replicas within 30 seconds; a run in progress is not interrupted. Every replica fires the schedule but only one of them executes each run.",
                 "consumes": [
                     "application/json"
                 ],
@@ -194,23 +394,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "leaderboard"
+                    "schedules"
                 ],
-                "summary": "Start leaderboard syncing",
+                "summary": "Update a schedule",
                 "parameters": [
                     {
-                        "description": "Sync start request (page number to begin from)",
-                        "name": "body",
+                        "type": "string",
+                        "description": "Schedule name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New spec and/or enabled flag",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.StartSyncingReq"
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.UpdateScheduleRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Syncing started",
+                        "description": "Updated schedule",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ScheduleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid spec",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -218,8 +431,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -230,9 +452,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/sync-status": {
+        "/api/v1/schedules/{name}/runs": {
             "get": {
-                "description": "Returns whether the leaderboard syncing process is active and current progress info.",
+                "description": "Returns the recorded runs of a schedule, newest first, with their status (running, succeeded, failed, skipped) and error.",
                 "consumes": [
                     "application/json"
                 ],
@@ -240,24 +462,1217 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "leaderboard"
+                    "schedules"
+                ],
+                "summary": "List schedule runs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number (1-based)",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1–100)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
-                "summary": "Get syncing status",
                 "responses": {
                     "200": {
-                        "description": "Current sync status",
+                        "description": "Schedule runs",
                         "schema": {
-                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.GetSyncStatusResponse"
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListScheduleRunsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.UserDatum": {
-            "type": "object",
-            "properties": {
+        },
+        "/api/v1/stop-syncing": {
+            "post": {
+                "description": "Stops the ongoing background leaderboard sync job. Same as /api/v1/sync/cancel.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Stop leaderboard syncing",
+                "responses": {
+                    "200": {
+                        "description": "Syncing stopped",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "No sync job to stop",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync-jobs": {
+            "get": {
+                "description": "Returns persisted sync jobs, newest first, with their page range, checkpoint, counters and status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "List sync jobs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (1-based)",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1–100)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sync jobs",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListSyncJobsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync-jobs/{id}/leases": {
+            "get": {
+                "description": "Returns the page ranges of a distributed sync job with their status, owner replica, lease expiry and checkpoint.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "List the page leases of a sync job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Sync job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page leases",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListSyncJobLeasesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Sync job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync-jobs/{id}/report": {
+            "get": {
+                "description": "Returns what a dry-run sync would have changed: new users, changed fields with old and new values, rank movements and users that disappeared from the ranking.\nformat=csv downloads the changes as CSV (job_id, username, change, field, old_value, new_value, created_at) instead of JSON.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Download the report of a dry-run sync job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Sync job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default) or csv",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dry-run report",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncJobReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID or format, or the job is not a dry run",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Sync job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync-leaderboard": {
+            "post": {
+                "description": "Starts the background process to sync the leaderboard from LeetCode.\nPass job_id to resume a stopped or failed job from its last committed page.\nmode=incremental only re-fetches users that are new, not refreshed within max_age_hours (default 168) or whose contest rating moved.\nmode=ranking skips the per-user profile queries and only writes profile, country, contest rating and rank from the ranking pages; solved counts are left as they are.\ndistributed=true splits the pages into leases that every running replica claims and sweeps.\ndry_run=true fetches as usual but writes nothing; download what it would change from /api/v1/sync-jobs/{id}/report.\nusernames, country or not_updated_since (set one) refresh that set of users instead of sweeping the ranking: the listed users, everyone stored for a country code, or stored users whose stats were not fetched since the given time.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Start leaderboard syncing",
+                "parameters": [
+                    {
+                        "description": "Sync start request (page number to begin from, or job to resume)",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.StartSyncingReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Syncing started",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or conflicting sync options",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "A sync job is already running",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync-status": {
+            "get": {
+                "description": "Returns the sync controller state (idle, running, paused, cancelling, finished, failed), current progress and the latest persisted job.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Get syncing status",
+                "responses": {
+                    "200": {
+                        "description": "Current sync status",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.GetSyncStatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/cancel": {
+            "post": {
+                "description": "Cancels the running or paused sync job. The job is recorded as stopped and can be resumed later by job_id.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Cancel leaderboard syncing",
+                "responses": {
+                    "200": {
+                        "description": "Syncing cancelling",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "No sync job to cancel",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/pause": {
+            "post": {
+                "description": "Pauses the running sync job. Requests already in flight finish, no new ones are sent until resumed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Pause leaderboard syncing",
+                "responses": {
+                    "200": {
+                        "description": "Syncing paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Sync is not running",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/resume": {
+            "post": {
+                "description": "Resumes a paused sync job.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "leaderboard"
+                ],
+                "summary": "Resume leaderboard syncing",
+                "responses": {
+                    "200": {
+                        "description": "Syncing resumed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Sync is not paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tracked-groups": {
+            "get": {
+                "description": "Returns the tracked groups with their member counts. Members are refreshed in the tracked priority tier.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "List tracked groups",
+                "responses": {
+                    "200": {
+                        "description": "Tracked groups",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListTrackedGroupsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "Create a tracked group",
+                "parameters": [
+                    {
+                        "description": "Group name",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.CreateTrackedGroupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created group",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.TrackedGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Group already exists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tracked-groups/{name}": {
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "Delete a tracked group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Group deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tracked-groups/{name}/members": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "List tracked group members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Group members",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.ListTrackedGroupMembersResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds LeetCode usernames to a group. Users that are not stored yet are fetched on the next priority refresh pass.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "Add tracked group members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Usernames to add",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.AddTrackedGroupMembersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Members added",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tracked-groups/{name}/members/{username}": {
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracked-groups"
+                ],
+                "summary": "Remove a tracked group member",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "LeetCode username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Member removed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{username}/history": {
+            "get": {
+                "description": "Returns solved, submission, rating and rank snapshots recorded by syncs, one point per day, week or month (the last snapshot in each bucket).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user's stats history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "LeetCode username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date, inclusive (YYYY-MM-DD, default 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date, inclusive (YYYY-MM-DD, default today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month"
+                        ],
+                        "type": "string",
+                        "description": "Bucket size: day (default), week or month",
+                        "name": "granularity",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stats time series",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.GetUserHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.FailedUserFetch": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "error_class": {
+                    "type": "string"
+                },
+                "first_failed_at": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "$ref": "#/definitions/sql.NullInt64"
+                },
+                "last_attempt_at": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "page": {
+                    "$ref": "#/definitions/sql.NullInt32"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.GetUserStatsHistoryRow": {
+            "type": "object",
+            "properties": {
+                "all_submissions": {
+                    "type": "integer"
+                },
+                "bucket": {
+                    "type": "string"
+                },
+                "captured_at": {
+                    "type": "string"
+                },
+                "contest_rating": {
+                    "$ref": "#/definitions/sql.NullFloat64"
+                },
+                "easy_solved": {
+                    "type": "integer"
+                },
+                "global_ranking": {
+                    "$ref": "#/definitions/sql.NullInt32"
+                },
+                "hard_solved": {
+                    "type": "integer"
+                },
+                "medium_solved": {
+                    "type": "integer"
+                },
+                "total_problems_solved": {
+                    "type": "integer"
+                },
+                "total_submissions": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.ListTrackedGroupsRow": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "member_count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.ListUsersByAccountStatusRow": {
+            "type": "object",
+            "properties": {
+                "account_status": {
+                    "type": "string"
+                },
+                "last_seen_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "miss_count": {
+                    "type": "integer"
+                },
+                "missing_since": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "renamed_to": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "status_changed_at": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.ScheduleRun": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "finished_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "heartbeat_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "schedule_name": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJob": {
+            "type": "object",
+            "properties": {
+                "batch_size": {
+                    "type": "integer"
+                },
+                "checkpoint_page": {
+                    "type": "integer"
+                },
+                "checkpoint_username": {
+                    "type": "string"
+                },
+                "delay_ms": {
+                    "type": "integer"
+                },
+                "distributed": {
+                    "type": "boolean"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "end_page": {
+                    "type": "integer"
+                },
+                "error_count": {
+                    "type": "integer"
+                },
+                "failed_pages": {
+                    "type": "integer"
+                },
+                "failed_users": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "heartbeat_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "lease_pages": {
+                    "type": "integer"
+                },
+                "max_age_seconds": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "owner": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "processed_users": {
+                    "type": "integer"
+                },
+                "skipped_users": {
+                    "type": "integer"
+                },
+                "start_page": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                },
+                "target_country": {
+                    "type": "string"
+                },
+                "target_updated_before": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "target_usernames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "total_pages": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJobChange": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "new_value": {
+                    "type": "string"
+                },
+                "old_value": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncPageLease": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "checkpoint_page": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "end_page": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "lease_expires_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "owner": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "start_page": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.TrackedGroup": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_db_users_storage.UserDatum": {
+            "type": "object",
+            "properties": {
+                "account_status": {
+                    "type": "string"
+                },
+                "all_submissions": {
+                    "type": "integer"
+                },
+                "contest_rankings": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "contest_rating": {
+                    "$ref": "#/definitions/sql.NullFloat64"
+                },
                 "country_code": {
                     "$ref": "#/definitions/sql.NullString"
                 },
@@ -267,12 +1682,57 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "data_region": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "easy_solved": {
+                    "type": "integer"
+                },
+                "easy_submissions": {
+                    "type": "integer"
+                },
+                "global_ranking": {
+                    "$ref": "#/definitions/sql.NullInt32"
+                },
+                "hard_solved": {
+                    "type": "integer"
+                },
+                "hard_submissions": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "last_missed_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "last_seen_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "medium_solved": {
+                    "type": "integer"
+                },
+                "medium_submissions": {
+                    "type": "integer"
+                },
+                "miss_count": {
+                    "type": "integer"
+                },
+                "missing_since": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
                 "real_name": {
                     "$ref": "#/definitions/sql.NullString"
                 },
+                "renamed_to": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "stats_fetched_at": {
+                    "$ref": "#/definitions/sql.NullTime"
+                },
+                "status_changed_at": {
+                    "type": "string"
+                },
                 "total_problems_solved": {
                     "type": "integer"
                 },
@@ -282,13 +1742,107 @@ const docTemplate = `{
                 "typename": {
                     "$ref": "#/definitions/sql.NullString"
                 },
-                "updated_at": {
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_avatar": {
+                    "$ref": "#/definitions/sql.NullString"
+                },
+                "user_slug": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.AddTrackedGroupMembersRequest": {
+            "type": "object",
+            "required": [
+                "usernames"
+            ],
+            "properties": {
+                "usernames": {
+                    "type": "array",
+                    "maxItems": 500,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.CreateTrackedGroupRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.CreateUserRequest": {
+            "type": "object",
+            "properties": {
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.GetSyncStatusResponse": {
+            "type": "object",
+            "properties": {
+                "finished_at": {
+                    "type": "string"
+                },
+                "is_on": {
+                    "type": "boolean"
+                },
+                "job": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJob"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pipeline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncStageMetrics"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.GetUserHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "granularity": {
                     "type": "string"
                 },
-                "user_avatar": {
-                    "$ref": "#/definitions/sql.NullString"
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.GetUserStatsHistoryRow"
+                    }
                 },
-                "user_slug": {
+                "to": {
                     "type": "string"
                 },
                 "username": {
@@ -296,26 +1850,61 @@ const docTemplate = `{
                 }
             }
         },
-        "github_com_ruziba3vich_leetcode_ranking_internal_dto.CreateUserRequest": {
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.GetUsersByCountryResponse": {
             "type": "object",
+            "required": [
+                "limit",
+                "page"
+            ],
             "properties": {
-                "username": {
-                    "type": "string"
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "total_count": {
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.UserDatum"
+                    }
                 }
             }
         },
-        "github_com_ruziba3vich_leetcode_ranking_internal_dto.GetSyncStatusResponse": {
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListAccountsResponse": {
             "type": "object",
+            "required": [
+                "limit",
+                "page"
+            ],
             "properties": {
-                "is_on": {
-                    "type": "boolean"
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
                 },
                 "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "total_count": {
                     "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.ListUsersByAccountStatusRow"
+                    }
                 }
             }
         },
-        "github_com_ruziba3vich_leetcode_ranking_internal_dto.GetUsersByCountryResponse": {
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListFailedUsersResponse": {
             "type": "object",
             "required": [
                 "limit",
@@ -337,16 +1926,290 @@ const docTemplate = `{
                 "users": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.UserDatum"
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.FailedUserFetch"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListScheduleRunsResponse": {
+            "type": "object",
+            "required": [
+                "limit",
+                "page"
+            ],
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "runs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.ScheduleRun"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListSyncJobLeasesResponse": {
+            "type": "object",
+            "properties": {
+                "leases": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncPageLease"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListSyncJobsResponse": {
+            "type": "object",
+            "required": [
+                "limit",
+                "page"
+            ],
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJob"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListTrackedGroupMembersResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "usernames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ListTrackedGroupsResponse": {
+            "type": "object",
+            "properties": {
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.ListTrackedGroupsRow"
                     }
                 }
             }
         },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.RetryFailedUsersRequest": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 1
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.ScheduleResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "last_run": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.ScheduleRun"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run": {
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "spec": {
+                    "type": "string"
+                }
+            }
+        },
         "github_com_ruziba3vich_leetcode_ranking_internal_dto.StartSyncingReq": {
             "type": "object",
             "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "distributed": {
+                    "description": "distributed splits the pages into leases that every running replica works on",
+                    "type": "boolean"
+                },
+                "dry_run": {
+                    "description": "dry_run fetches as usual but only reports what the sync would change",
+                    "type": "boolean"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "max_age_hours": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "mode": {
+                    "description": "incremental only re-fetches users that are new, older than max_age_hours or whose rating moved;\nranking only writes profile, rating and rank from the ranking pages",
+                    "type": "string",
+                    "enum": [
+                        "full",
+                        "incremental",
+                        "ranking"
+                    ]
+                },
+                "not_updated_since": {
+                    "type": "string"
+                },
                 "page": {
                     "type": "integer"
+                },
+                "usernames": {
+                    "description": "usernames, country and not_updated_since refresh a set of users instead of\nsweeping the ranking pages; at most one of them may be set",
+                    "type": "array",
+                    "maxItems": 10000,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncJobReport": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJobChange"
+                    }
+                },
+                "job": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_db_users_storage.SyncJob"
+                },
+                "summary": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncJobReportSummary"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncJobReportSummary": {
+            "type": "object",
+            "properties": {
+                "changed_users": {
+                    "type": "integer"
+                },
+                "disappeared": {
+                    "type": "integer"
+                },
+                "field_changes": {
+                    "type": "integer"
+                },
+                "new_users": {
+                    "type": "integer"
+                },
+                "rank_movements": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.SyncStageMetrics": {
+            "type": "object",
+            "properties": {
+                "blocked_seconds": {
+                    "type": "number"
+                },
+                "busy_seconds": {
+                    "type": "number"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "in": {
+                    "type": "integer"
+                },
+                "out": {
+                    "type": "integer"
+                },
+                "queue_capacity": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "stage": {
+                    "type": "string"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_ruziba3vich_leetcode_ranking_internal_dto.UpdateScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "spec": {
+                    "type": "string"
+                }
+            }
+        },
+        "sql.NullFloat64": {
+            "type": "object",
+            "properties": {
+                "float64": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "valid": {
+                    "description": "Valid is true if Float64 is not NULL",
+                    "type": "boolean"
+                }
+            }
+        },
+        "sql.NullInt32": {
+            "type": "object",
+            "properties": {
+                "int32": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "valid": {
+                    "description": "Valid is true if Int32 is not NULL",
+                    "type": "boolean"
+                }
+            }
+        },
+        "sql.NullInt64": {
+            "type": "object",
+            "properties": {
+                "int64": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "valid": {
+                    "description": "Valid is true if Int64 is not NULL",
+                    "type": "boolean"
                 }
             }
         },
@@ -361,6 +2224,18 @@ const docTemplate = `{
                     "type": "boolean"
                 }
             }
+        },
+        "sql.NullTime": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "type": "string"
+                },
+                "valid": {
+                    "description": "Valid is true if Time is not NULL",
+                    "type": "boolean"
+                }
+            }
         }
     }
 }`
@@ -1,6 +1,10 @@
 package dto
 
-import "github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+import (
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+)
 
 type (
 	CreateUserRequest struct {
@@ -10,6 +14,7 @@ type (
 	GetUsersByCountry struct {
 		PageLimit
 		Country string `form:"country" binding:"required"`
+		OrderBy string `form:"order_by" binding:"omitempty,oneof=solved rating hard"`
 	}
 
 	PageLimit struct {
@@ -23,12 +28,156 @@ type (
 		PageLimit
 	}
 
+	GetUserHistoryRequest struct {
+		Username    string    `uri:"username" binding:"required"`
+		From        time.Time `form:"from" time_format:"2006-01-02"`
+		To          time.Time `form:"to" time_format:"2006-01-02"`
+		Granularity string    `form:"granularity" binding:"omitempty,oneof=day week month"`
+	}
+
+	GetUserHistoryResponse struct {
+		Username    string                                 `json:"username"`
+		Granularity string                                 `json:"granularity"`
+		From        time.Time                              `json:"from"`
+		To          time.Time                              `json:"to"`
+		Points      []users_storage.GetUserStatsHistoryRow `json:"points"`
+	}
+
 	StartSyncingReq struct {
-		Page int `json:"page"`
+		Page  int   `json:"page"`
+		JobID int64 `json:"job_id"`
+		// incremental only re-fetches users that are new, older than max_age_hours or whose rating moved;
+		// ranking only writes profile, rating and rank from the ranking pages
+		Mode        string `json:"mode" binding:"omitempty,oneof=full incremental ranking"`
+		MaxAgeHours int    `json:"max_age_hours" binding:"omitempty,min=1"`
+		// distributed splits the pages into leases that every running replica works on
+		Distributed bool `json:"distributed"`
+		// usernames, country and not_updated_since refresh a set of users instead of
+		// sweeping the ranking pages; at most one of them may be set
+		Usernames       []string   `json:"usernames" binding:"omitempty,max=10000"`
+		Country         string     `json:"country" binding:"omitempty,len=2"`
+		NotUpdatedSince *time.Time `json:"not_updated_since"`
+		// dry_run fetches as usual but only reports what the sync would change
+		DryRun bool `json:"dry_run"`
 	}
 
 	GetSyncStatusResponse struct {
-		IsOn bool `json:"is_on"`
-		Page int  `json:"page"`
+		IsOn       bool                   `json:"is_on"`
+		State      string                 `json:"state"`
+		JobID      int64                  `json:"job_id,omitempty"`
+		Page       int                    `json:"page"`
+		LastError  string                 `json:"last_error,omitempty"`
+		StartedAt  *time.Time             `json:"started_at,omitempty"`
+		FinishedAt *time.Time             `json:"finished_at,omitempty"`
+		Job        *users_storage.SyncJob `json:"job,omitempty"`
+		Pipeline   []SyncStageMetrics     `json:"pipeline,omitempty"`
+	}
+
+	// SyncStageMetrics reports one stage of the sync pipeline. blocked_seconds is
+	// time spent waiting for the next stage to take output, i.e. back-pressure.
+	SyncStageMetrics struct {
+		Stage          string  `json:"stage"`
+		Workers        int     `json:"workers"`
+		In             int64   `json:"in"`
+		Out            int64   `json:"out"`
+		Errors         int64   `json:"errors"`
+		QueueDepth     int     `json:"queue_depth"`
+		QueueCapacity  int     `json:"queue_capacity"`
+		BusySeconds    float64 `json:"busy_seconds"`
+		BlockedSeconds float64 `json:"blocked_seconds"`
+	}
+
+	ListSyncJobsResponse struct {
+		Jobs []users_storage.SyncJob `json:"jobs"`
+		PageLimit
+	}
+
+	ListSyncJobLeasesResponse struct {
+		Leases []users_storage.SyncPageLease `json:"leases"`
+	}
+
+	// SyncJobReportSummary counts the changes of a dry-run report by kind.
+	// changed_users is the number of distinct users with at least one field change.
+	SyncJobReportSummary struct {
+		NewUsers      int `json:"new_users"`
+		ChangedUsers  int `json:"changed_users"`
+		FieldChanges  int `json:"field_changes"`
+		RankMovements int `json:"rank_movements"`
+		Disappeared   int `json:"disappeared"`
+	}
+
+	SyncJobReport struct {
+		Job     users_storage.SyncJob         `json:"job"`
+		Summary SyncJobReportSummary          `json:"summary"`
+		Changes []users_storage.SyncJobChange `json:"changes"`
+	}
+
+	ListFailedUsersRequest struct {
+		PageLimit
+		Status string `form:"status" binding:"omitempty,oneof=pending permanent"`
+	}
+
+	ListFailedUsersResponse struct {
+		Users      []users_storage.FailedUserFetch `json:"users"`
+		TotalCount int64                           `json:"total_count"`
+		PageLimit
+	}
+
+	ListAccountsRequest struct {
+		PageLimit
+		Status string `form:"status" binding:"omitempty,oneof=active missing renamed deleted"`
+	}
+
+	ListAccountsResponse struct {
+		Users      []users_storage.ListUsersByAccountStatusRow `json:"users"`
+		TotalCount int64                                       `json:"total_count"`
+		PageLimit
+	}
+
+	RetryFailedUsersRequest struct {
+		Limit int `json:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+
+	RetryFailedUsersResponse struct {
+		Attempted    int `json:"attempted"`
+		Recovered    int `json:"recovered"`
+		StillPending int `json:"still_pending"`
+		Permanent    int `json:"permanent"`
+	}
+
+	ScheduleResponse struct {
+		Name    string                     `json:"name"`
+		Spec    string                     `json:"spec"`
+		Enabled bool                       `json:"enabled"`
+		Running bool                       `json:"running"`
+		NextRun *time.Time                 `json:"next_run,omitempty"`
+		LastRun *users_storage.ScheduleRun `json:"last_run,omitempty"`
+	}
+
+	UpdateScheduleRequest struct {
+		Spec    *string `json:"spec"`
+		Enabled *bool   `json:"enabled"`
+	}
+
+	ListScheduleRunsResponse struct {
+		Runs []users_storage.ScheduleRun `json:"runs"`
+		PageLimit
+	}
+
+	CreateTrackedGroupRequest struct {
+		Name string `json:"name" binding:"required,max=64"`
+	}
+
+	AddTrackedGroupMembersRequest struct {
+		Usernames []string `json:"usernames" binding:"required,min=1,max=500,dive,required"`
+	}
+
+	ListTrackedGroupsResponse struct {
+		Groups []users_storage.ListTrackedGroupsRow `json:"groups"`
+	}
+
+	ListTrackedGroupMembersResponse struct {
+		Name      string   `json:"name"`
+		Usernames []string `json:"usernames"`
 	}
 )
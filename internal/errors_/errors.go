@@ -3,5 +3,15 @@ package errors_
 import "errors"
 
 var (
-	ErrUserNotAvailable = errors.New("no user found with the provided username")
+	ErrUserNotAvailable      = errors.New("no user found with the provided username")
+	ErrSyncInProgress        = errors.New("syncing is already on")
+	ErrInvalidSyncTransition = errors.New("invalid sync state transition")
+	ErrRetryInProgress       = errors.New("failed users are already being retried")
+	ErrScheduleNotFound      = errors.New("schedule not found")
+	ErrInvalidSchedule       = errors.New("invalid schedule spec")
+	ErrGroupNotFound         = errors.New("tracked group not found")
+	ErrGroupExists           = errors.New("tracked group already exists")
+	ErrSyncJobNotFound       = errors.New("sync job not found")
+	ErrInvalidSyncOptions    = errors.New("invalid sync options")
+	ErrNotDryRun             = errors.New("sync job is not a dry run")
 )
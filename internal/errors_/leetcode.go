@@ -0,0 +1,99 @@
+package errors_
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrorClass tells callers what went wrong talking to LeetCode and whether it is worth retrying
+type ErrorClass string
+
+const (
+	ClassTransient    ErrorClass = "transient"      // network failure, timeout or 5xx
+	ClassThrottled    ErrorClass = "throttled"      // 429/403 from LeetCode
+	ClassGraphQL      ErrorClass = "graphql"        // request reached GraphQL but it reported errors
+	ClassUserNotFound ErrorClass = "user_not_found" // matchedUser came back null
+	ClassSchemaChange ErrorClass = "schema_change"  // response no longer matches what we decode
+)
+
+var (
+	ErrTransient     = errors.New("transient leetcode error")
+	ErrThrottled     = errors.New("throttled by leetcode")
+	ErrGraphQL       = errors.New("leetcode graphql error")
+	ErrSchemaChanged = errors.New("unexpected leetcode response")
+)
+
+// LeetCodeError is a classified failure of a LeetCode request
+type LeetCodeError struct {
+	Class      ErrorClass
+	StatusCode int           // 0 when no response was received
+	RetryAfter time.Duration // server supplied Retry-After, if any
+	Err        error
+}
+
+func (e *LeetCodeError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("leetcode %s (status=%d): %v", e.Class, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("leetcode %s: %v", e.Class, e.Err)
+}
+
+// Unwrap exposes both the class sentinel and the cause, so errors.Is works for either
+func (e *LeetCodeError) Unwrap() []error {
+	if sentinel := e.Class.sentinel(); sentinel != nil {
+		return []error{sentinel, e.Err}
+	}
+	return []error{e.Err}
+}
+
+func (c ErrorClass) sentinel() error {
+	switch c {
+	case ClassTransient:
+		return ErrTransient
+	case ClassThrottled:
+		return ErrThrottled
+	case ClassGraphQL:
+		return ErrGraphQL
+	case ClassUserNotFound:
+		return ErrUserNotAvailable
+	case ClassSchemaChange:
+		return ErrSchemaChanged
+	}
+	return nil
+}
+
+// NewLeetCodeError wraps err with class
+func NewLeetCodeError(class ErrorClass, statusCode int, err error) *LeetCodeError {
+	return &LeetCodeError{Class: class, StatusCode: statusCode, Err: err}
+}
+
+// ClassOf returns the class of err, or "" when err did not come from LeetCode
+func ClassOf(err error) ErrorClass {
+	var lcErr *LeetCodeError
+	if errors.As(err, &lcErr) {
+		return lcErr.Class
+	}
+	if errors.Is(err, ErrUserNotAvailable) {
+		return ClassUserNotFound
+	}
+	return ""
+}
+
+// IsRetryable reports whether repeating the same request may succeed
+func IsRetryable(err error) bool {
+	switch ClassOf(err) {
+	case ClassTransient, ClassThrottled:
+		return true
+	}
+	return false
+}
+
+// RetryAfterOf returns the Retry-After carried by err, if any
+func RetryAfterOf(err error) time.Duration {
+	var lcErr *LeetCodeError
+	if errors.As(err, &lcErr) {
+		return lcErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// ListSchedules godoc
+// @Summary     List schedules
+// @Description Returns the recurring jobs (full_sync, priority_refresh, failed_users) with their cron spec, next run and last run outcome.
+// @Tags        schedules
+// @Accept      json
+// @Produce     json
+// @Success     200   {array}  dto.ScheduleResponse "Schedules"
+// @Failure     500   {object} map[string]string    "Internal server error"
+// @Router      /api/v1/schedules [get]
+func (h *Handler) ListSchedules(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	schedules, err := h.scheduler.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// UpdateSchedule godoc
+// @Summary     Update a schedule
+// @Description Changes the cron spec (standard 5-field or @descriptor such as @hourly, @every 15m; @every fires on multiples of its interval) and/or enables or disables a schedule. The change is persisted and applied immediately, and reaches the other replicas within 30 seconds; a run in progress is not interrupted. Every replica fires the schedule but only one of them executes each run.
+// @Tags        schedules
+// @Accept      json
+// @Produce     json
+// @Param       name     path     string                     true "Schedule name"
+// @Param       request  body     dto.UpdateScheduleRequest  true "New spec and/or enabled flag"
+// @Success     200      {object} dto.ScheduleResponse       "Updated schedule"
+// @Failure     400      {object} map[string]string          "Invalid spec"
+// @Failure     404      {object} map[string]string          "Schedule not found"
+// @Failure     500      {object} map[string]string          "Internal server error"
+// @Router      /api/v1/schedules/{name} [put]
+func (h *Handler) UpdateSchedule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	response, err := h.scheduler.Update(ctx, c.Param("name"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors_.ErrScheduleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, errors_.ErrInvalidSchedule):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListScheduleRuns godoc
+// @Summary     List schedule runs
+// @Description Returns the recorded runs of a schedule, newest first, with their status (running, succeeded, failed, skipped) and error.
+// @Tags        schedules
+// @Accept      json
+// @Produce     json
+// @Param       name     path     string true  "Schedule name"
+// @Param       page     query    int    true  "Page number (1-based)"
+// @Param       limit    query    int    true  "Page size (1–100)"
+// @Success     200      {object} dto.ListScheduleRunsResponse "Schedule runs"
+// @Failure     400      {object} map[string]string            "Validation message"
+// @Failure     404      {object} map[string]string            "Schedule not found"
+// @Failure     500      {object} map[string]string            "Internal server error"
+// @Router      /api/v1/schedules/{name}/runs [get]
+func (h *Handler) ListScheduleRuns(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.PageLimit
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, err := h.scheduler.ListRuns(ctx, &users_storage.ListScheduleRunsParams{
+		ScheduleName: c.Param("name"),
+		Limit:        int32(req.Limit),
+		Offset:       int32((req.Page - 1) * req.Limit),
+	})
+	if err != nil {
+		if errors.Is(err, errors_.ErrScheduleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &dto.ListScheduleRunsResponse{
+		Runs:      runs,
+		PageLimit: req,
+	})
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// ListTrackedGroups godoc
+// @Summary     List tracked groups
+// @Description Returns the tracked groups with their member counts. Members are refreshed in the tracked priority tier.
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Success     200   {object} dto.ListTrackedGroupsResponse "Tracked groups"
+// @Failure     500   {object} map[string]string             "Internal server error"
+// @Router      /api/v1/tracked-groups [get]
+func (h *Handler) ListTrackedGroups(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	groups, err := h.srv.ListTrackedGroups(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, &dto.ListTrackedGroupsResponse{Groups: groups})
+}
+
+// CreateTrackedGroup godoc
+// @Summary     Create a tracked group
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Param       request  body     dto.CreateTrackedGroupRequest true "Group name"
+// @Success     201      {object} users_storage.TrackedGroup     "Created group"
+// @Failure     400      {object} map[string]string              "Validation message"
+// @Failure     409      {object} map[string]string              "Group already exists"
+// @Failure     500      {object} map[string]string              "Internal server error"
+// @Router      /api/v1/tracked-groups [post]
+func (h *Handler) CreateTrackedGroup(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.CreateTrackedGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var group *users_storage.TrackedGroup
+	group, err := h.srv.CreateTrackedGroup(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, errors_.ErrGroupExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// DeleteTrackedGroup godoc
+// @Summary     Delete a tracked group
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Param       name  path     string            true "Group name"
+// @Success     200   {object} map[string]string "Group deleted"
+// @Failure     404   {object} map[string]string "Group not found"
+// @Failure     500   {object} map[string]string "Internal server error"
+// @Router      /api/v1/tracked-groups/{name} [delete]
+func (h *Handler) DeleteTrackedGroup(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.srv.DeleteTrackedGroup(ctx, c.Param("name")); err != nil {
+		h.trackedGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "group deleted"})
+}
+
+// ListTrackedGroupMembers godoc
+// @Summary     List tracked group members
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Param       name  path     string                              true "Group name"
+// @Success     200   {object} dto.ListTrackedGroupMembersResponse "Group members"
+// @Failure     404   {object} map[string]string                   "Group not found"
+// @Failure     500   {object} map[string]string                   "Internal server error"
+// @Router      /api/v1/tracked-groups/{name}/members [get]
+func (h *Handler) ListTrackedGroupMembers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	name := c.Param("name")
+	members, err := h.srv.ListTrackedGroupMembers(ctx, name)
+	if err != nil {
+		h.trackedGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &dto.ListTrackedGroupMembersResponse{
+		Name:      name,
+		Usernames: members,
+	})
+}
+
+// AddTrackedGroupMembers godoc
+// @Summary     Add tracked group members
+// @Description Adds LeetCode usernames to a group. Users that are not stored yet are fetched on the next priority refresh pass.
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Param       name     path     string                            true "Group name"
+// @Param       request  body     dto.AddTrackedGroupMembersRequest true "Usernames to add"
+// @Success     200      {object} map[string]string                 "Members added"
+// @Failure     400      {object} map[string]string                 "Validation message"
+// @Failure     404      {object} map[string]string                 "Group not found"
+// @Failure     500      {object} map[string]string                 "Internal server error"
+// @Router      /api/v1/tracked-groups/{name}/members [post]
+func (h *Handler) AddTrackedGroupMembers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.AddTrackedGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.srv.AddTrackedGroupMembers(ctx, c.Param("name"), req.Usernames); err != nil {
+		h.trackedGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "members added"})
+}
+
+// RemoveTrackedGroupMember godoc
+// @Summary     Remove a tracked group member
+// @Tags        tracked-groups
+// @Accept      json
+// @Produce     json
+// @Param       name      path     string            true "Group name"
+// @Param       username  path     string            true "LeetCode username"
+// @Success     200       {object} map[string]string "Member removed"
+// @Failure     404       {object} map[string]string "Group not found"
+// @Failure     500       {object} map[string]string "Internal server error"
+// @Router      /api/v1/tracked-groups/{name}/members/{username} [delete]
+func (h *Handler) RemoveTrackedGroupMember(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.srv.RemoveTrackedGroupMember(ctx, c.Param("name"), c.Param("username")); err != nil {
+		h.trackedGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "member removed"})
+}
+
+func (h *Handler) trackedGroupError(c *gin.Context, err error) {
+	if errors.Is(err, errors_.ErrGroupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
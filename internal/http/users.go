@@ -2,27 +2,33 @@ package http
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
 	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
 	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	"github.com/ruziba3vich/leetcode_ranking/internal/scheduler"
 	"github.com/ruziba3vich/leetcode_ranking/internal/service"
 	logger "github.com/ruziba3vich/prodonik_lgger"
 )
 
 type Handler struct {
-	srv    service.UserService
-	logger *logger.Logger
+	srv       service.UserService
+	scheduler *scheduler.Scheduler
+	logger    *logger.Logger
 }
 
-func NewHandler(srv service.UserService, logger *logger.Logger) *Handler {
+func NewHandler(srv service.UserService, scheduler *scheduler.Scheduler, logger *logger.Logger) *Handler {
 	return &Handler{
-		srv:    srv,
-		logger: logger,
+		srv:       srv,
+		scheduler: scheduler,
+		logger:    logger,
 	}
 }
 
@@ -37,6 +43,7 @@ func NewHandler(srv service.UserService, logger *logger.Logger) *Handler {
 // @Failure     400   {object} map[string]string      "Bad request"
 // @Failure     404   {object} map[string]string      "User not available"
 // @Failure     500   {object} map[string]string      "Internal server error"
+// @Failure     503   {object} map[string]string      "LeetCode unavailable or throttling"
 // @Router      /api/v1/add-user [post]
 func (h *Handler) CreateUser(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
@@ -53,6 +60,10 @@ func (h *Handler) CreateUser(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": errors_.ErrUserNotAvailable.Error()})
 			return
 		}
+		if errors_.IsRetryable(err) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "leetcode is not responding, try again later"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
@@ -63,12 +74,15 @@ func (h *Handler) CreateUser(c *gin.Context) {
 // GetUsersByCountry godoc
 // @Summary     List users by country (paginated, ranked)
 // @Description Returns users filtered by 2-letter country code, ordered by total_problems_solved DESC, then total_submissions ASC, then username ASC.
+// @Description With order_by=rating users are ranked by contest rating first; users without a rating come last.
+// @Description With order_by=hard users are ranked by hard problems solved first.
 // @Tags        users
 // @Accept      json
 // @Produce     json
 // @Param       country  query    string true  "ISO-3166-1 alpha-2 country code (e.g., US, CN, SG)"
 // @Param       page     query    int    true  "Page number (1-based)"
 // @Param       limit    query    int    true  "Page size (1–100)"
+// @Param       order_by query    string false "Ranking order: solved (default), rating or hard" Enums(solved, rating, hard)
 // @Success     200      {object} dto.GetUsersByCountryResponse "List of users by country"
 // @Failure     400      {object} map[string]string     "Validation message"
 // @Failure     500      {object} map[string]string     "Internal server error"
@@ -87,10 +101,15 @@ func (h *Handler) GetUsersByCountry(c *gin.Context) {
 		return
 	}
 
+	if len(req.OrderBy) == 0 {
+		req.OrderBy = "solved"
+	}
+
 	offset := (req.Page - 1) * req.Limit
 
 	response, err := h.srv.GetUsersByCountry(ctx, &users_storage.GetUsersByCountryParams{
 		Country:   req.Country,
+		OrderBy:   req.OrderBy,
 		LimitArg:  int32(req.Limit),
 		OffsetArg: int32(offset),
 	})
@@ -103,15 +122,76 @@ func (h *Handler) GetUsersByCountry(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetUserHistory godoc
+// @Summary     Get a user's stats history
+// @Description Returns solved, submission, rating and rank snapshots recorded by syncs, one point per day, week or month (the last snapshot in each bucket).
+// @Tags        users
+// @Accept      json
+// @Produce     json
+// @Param       username     path     string true  "LeetCode username"
+// @Param       from         query    string false "Start date, inclusive (YYYY-MM-DD, default 30 days before to)"
+// @Param       to           query    string false "End date, inclusive (YYYY-MM-DD, default today)"
+// @Param       granularity  query    string false "Bucket size: day (default), week or month" Enums(day, week, month)
+// @Success     200          {object} dto.GetUserHistoryResponse "Stats time series"
+// @Failure     400          {object} map[string]string         "Validation message"
+// @Failure     500          {object} map[string]string         "Internal server error"
+// @Router      /api/v1/users/{username}/history [get]
+func (h *Handler) GetUserHistory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.GetUserHistoryRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Granularity) == 0 {
+		req.Granularity = "day"
+	}
+	if req.To.IsZero() {
+		now := time.Now().UTC()
+		req.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if req.From.IsZero() {
+		req.From = req.To.AddDate(0, 0, -30)
+	}
+	// make "to" inclusive of the whole day
+	req.To = req.To.AddDate(0, 0, 1)
+	if !req.From.Before(req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	response, err := h.srv.GetUserHistory(ctx, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // SyncLeaderboard godoc
 // @Summary     Start leaderboard syncing
 // @Description Starts the background process to sync the leaderboard from LeetCode.
+// @Description Pass job_id to resume a stopped or failed job from its last committed page.
+// @Description mode=incremental only re-fetches users that are new, not refreshed within max_age_hours (default 168) or whose contest rating moved.
+// @Description mode=ranking skips the per-user profile queries and only writes profile, country, contest rating and rank from the ranking pages; solved counts are left as they are.
+// @Description distributed=true splits the pages into leases that every running replica claims and sweeps.
+// @Description dry_run=true fetches as usual but writes nothing; download what it would change from /api/v1/sync-jobs/{id}/report.
+// @Description usernames, country or not_updated_since (set one) refresh that set of users instead of sweeping the ranking: the listed users, everyone stored for a country code, or stored users whose stats were not fetched since the given time.
 // @Tags        leaderboard
 // @Accept      json
 // @Produce     json
-// @Param       body  body     dto.StartSyncingReq  true  "Sync start request (page number to begin from)"
+// @Param       body  body     dto.StartSyncingReq  true  "Sync start request (page number to begin from, or job to resume)"
 // @Success     200   {object} map[string]string    "Syncing started"
-// @Failure     400   {object} map[string]string    "Invalid request"
+// @Failure     400   {object} map[string]string    "Invalid request or conflicting sync options"
+// @Failure     409   {object} map[string]string    "A sync job is already running"
 // @Router      /api/v1/sync-leaderboard [post]
 func (h *Handler) SyncLeaderboard(c *gin.Context) {
 	var req dto.StartSyncingReq
@@ -120,39 +200,381 @@ func (h *Handler) SyncLeaderboard(c *gin.Context) {
 		return
 	}
 
-	stat := h.srv.GetSyncStatus()
-	if stat.IsOn {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "syncing is already on"})
+	opts := service.SyncOptions{
+		StartPage:   req.Page,
+		Workers:     4,
+		JobID:       req.JobID,
+		Mode:        service.SyncMode(req.Mode),
+		MaxAge:      time.Duration(req.MaxAgeHours) * time.Hour,
+		Distributed: req.Distributed,
+		DryRun:      req.DryRun,
+		Target: service.SyncTarget{
+			Usernames: req.Usernames,
+			Country:   req.Country,
+		},
+	}
+	if req.NotUpdatedSince != nil {
+		opts.Target.NotUpdatedSince = *req.NotUpdatedSince
+	}
+	if err := h.srv.StartSync(opts); err != nil {
+		h.syncControlError(c, err)
 		return
 	}
-
-	h.srv.SyncOn()
-	go h.srv.SyncLeaderboard(c.Request.Context(), service.SyncOptions{StartPage: req.Page, Workers: 4})
 	c.JSON(http.StatusOK, gin.H{"response": "syncing started"})
 }
 
 // StopSyncing godoc
 // @Summary     Stop leaderboard syncing
-// @Description Stops the ongoing background leaderboard sync job.
+// @Description Stops the ongoing background leaderboard sync job. Same as /api/v1/sync/cancel.
 // @Tags        leaderboard
 // @Accept      json
 // @Produce     json
 // @Success     200   {object} map[string]string    "Syncing stopped"
-// @Failure     400   {object} map[string]string    "Invalid request"
+// @Failure     409   {object} map[string]string    "No sync job to stop"
 // @Router      /api/v1/stop-syncing [post]
 func (h *Handler) StopSyncing(c *gin.Context) {
-	h.srv.SyncOff()
+	if err := h.srv.CancelSync(); err != nil {
+		h.syncControlError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"response": "syncing stopped"})
 }
 
+// PauseSync godoc
+// @Summary     Pause leaderboard syncing
+// @Description Pauses the running sync job. Requests already in flight finish, no new ones are sent until resumed.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Success     200   {object} map[string]string    "Syncing paused"
+// @Failure     409   {object} map[string]string    "Sync is not running"
+// @Router      /api/v1/sync/pause [post]
+func (h *Handler) PauseSync(c *gin.Context) {
+	if err := h.srv.PauseSync(); err != nil {
+		h.syncControlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "syncing paused"})
+}
+
+// ResumeSync godoc
+// @Summary     Resume leaderboard syncing
+// @Description Resumes a paused sync job.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Success     200   {object} map[string]string    "Syncing resumed"
+// @Failure     409   {object} map[string]string    "Sync is not paused"
+// @Router      /api/v1/sync/resume [post]
+func (h *Handler) ResumeSync(c *gin.Context) {
+	if err := h.srv.ResumeSync(); err != nil {
+		h.syncControlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "syncing resumed"})
+}
+
+// CancelSync godoc
+// @Summary     Cancel leaderboard syncing
+// @Description Cancels the running or paused sync job. The job is recorded as stopped and can be resumed later by job_id.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Success     200   {object} map[string]string    "Syncing cancelling"
+// @Failure     409   {object} map[string]string    "No sync job to cancel"
+// @Router      /api/v1/sync/cancel [post]
+func (h *Handler) CancelSync(c *gin.Context) {
+	if err := h.srv.CancelSync(); err != nil {
+		h.syncControlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"response": "syncing cancelling"})
+}
+
+func (h *Handler) syncControlError(c *gin.Context, err error) {
+	if errors.Is(err, errors_.ErrInvalidSyncOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errors_.ErrSyncInProgress) || errors.Is(err, errors_.ErrInvalidSyncTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Error("sync control failed", map[string]any{"error": err})
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
 // GetSyncingStatus godoc
 // @Summary     Get syncing status
-// @Description Returns whether the leaderboard syncing process is active and current progress info.
+// @Description Returns the sync controller state (idle, running, paused, cancelling, finished, failed), current progress and the latest persisted job.
 // @Tags        leaderboard
 // @Accept      json
 // @Produce     json
 // @Success     200   {object} dto.GetSyncStatusResponse "Current sync status"
+// @Failure     500   {object} map[string]string         "Internal server error"
 // @Router      /api/v1/sync-status [get]
 func (h *Handler) GetSyncingStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, h.srv.GetSyncStatus())
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	response, err := h.srv.GetSyncStatus(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListSyncJobs godoc
+// @Summary     List sync jobs
+// @Description Returns persisted sync jobs, newest first, with their page range, checkpoint, counters and status.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Param       page     query    int    true  "Page number (1-based)"
+// @Param       limit    query    int    true  "Page size (1–100)"
+// @Success     200      {object} dto.ListSyncJobsResponse "Sync jobs"
+// @Failure     400      {object} map[string]string        "Validation message"
+// @Failure     500      {object} map[string]string        "Internal server error"
+// @Router      /api/v1/sync-jobs [get]
+func (h *Handler) ListSyncJobs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.PageLimit
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, err := h.srv.ListSyncJobs(ctx, &users_storage.ListSyncJobsParams{
+		Limit:  int32(req.Limit),
+		Offset: int32((req.Page - 1) * req.Limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &dto.ListSyncJobsResponse{
+		Jobs:      jobs,
+		PageLimit: req,
+	})
+}
+
+// ListSyncJobLeases godoc
+// @Summary     List the page leases of a sync job
+// @Description Returns the page ranges of a distributed sync job with their status, owner replica, lease expiry and checkpoint.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Param       id   path     int    true  "Sync job ID"
+// @Success     200  {object} dto.ListSyncJobLeasesResponse "Page leases"
+// @Failure     400  {object} map[string]string             "Invalid job ID"
+// @Failure     404  {object} map[string]string             "Sync job not found"
+// @Failure     500  {object} map[string]string             "Internal server error"
+// @Router      /api/v1/sync-jobs/{id}/leases [get]
+func (h *Handler) ListSyncJobLeases(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || jobID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	leases, err := h.srv.ListSyncJobLeases(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, errors_.ErrSyncJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &dto.ListSyncJobLeasesResponse{Leases: leases})
+}
+
+// GetSyncJobReport godoc
+// @Summary     Download the report of a dry-run sync job
+// @Description Returns what a dry-run sync would have changed: new users, changed fields with old and new values, rank movements and users that disappeared from the ranking.
+// @Description format=csv downloads the changes as CSV (job_id, username, change, field, old_value, new_value, created_at) instead of JSON.
+// @Tags        leaderboard
+// @Accept      json
+// @Produce     json
+// @Produce     text/csv
+// @Param       id      path     int    true  "Sync job ID"
+// @Param       format  query    string false "json (default) or csv"
+// @Success     200     {object} dto.SyncJobReport "Dry-run report"
+// @Failure     400     {object} map[string]string "Invalid job ID or format, or the job is not a dry run"
+// @Failure     404     {object} map[string]string "Sync job not found"
+// @Failure     500     {object} map[string]string "Internal server error"
+// @Router      /api/v1/sync-jobs/{id}/report [get]
+func (h *Handler) GetSyncJobReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || jobID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	report, err := h.srv.GetSyncJobReport(ctx, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors_.ErrSyncJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, errors_.ErrNotDryRun):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("sync-job-%d-report.%s", jobID, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"job_id", "username", "change", "field", "old_value", "new_value", "created_at"})
+	for _, ch := range report.Changes {
+		_ = w.Write([]string{
+			strconv.FormatInt(ch.JobID, 10),
+			ch.Username,
+			ch.Change,
+			ch.Field,
+			ch.OldValue,
+			ch.NewValue,
+			ch.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.logger.Error("failed to write sync job report", map[string]any{"job_id": jobID, "error": err})
+	}
+}
+
+// ListFailedUsers godoc
+// @Summary     List failed user fetches
+// @Description Returns the dead-letter queue of users whose profile could not be fetched, with the ranking page, error class, attempt count and last error.
+// @Tags        failed-users
+// @Accept      json
+// @Produce     json
+// @Param       page     query    int    true  "Page number (1-based)"
+// @Param       limit    query    int    true  "Page size (1–100)"
+// @Param       status   query    string false "pending (default) or permanent"
+// @Success     200      {object} dto.ListFailedUsersResponse "Failed users"
+// @Failure     400      {object} map[string]string           "Validation message"
+// @Failure     500      {object} map[string]string           "Internal server error"
+// @Router      /api/v1/failed-users [get]
+func (h *Handler) ListFailedUsers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.ListFailedUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = service.FailedUserPending
+	}
+
+	response, err := h.srv.ListFailedUsers(ctx, &users_storage.ListFailedUserFetchesParams{
+		Status: req.Status,
+		Limit:  int32(req.Limit),
+		Offset: int32((req.Page - 1) * req.Limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	response.PageLimit = req.PageLimit
+	c.JSON(http.StatusOK, response)
+}
+
+// RetryFailedUsers godoc
+// @Summary     Retry failed user fetches
+// @Description Starts a background pass that re-fetches pending users from the dead-letter queue. Users that keep failing are marked permanent after the configured number of attempts; users LeetCode says do not exist are permanent at once. The pass also re-checks those whose account is still missing, each miss counting towards deleting the account.
+// @Tags        failed-users
+// @Accept      json
+// @Produce     json
+// @Param       request  body     dto.RetryFailedUsersRequest false "Maximum number of users to retry"
+// @Success     202      {object} map[string]string "Retry started"
+// @Failure     400      {object} map[string]string "Validation message"
+// @Failure     409      {object} map[string]string "A retry pass is already running"
+// @Router      /api/v1/failed-users/retry [post]
+func (h *Handler) RetryFailedUsers(c *gin.Context) {
+	var req dto.RetryFailedUsersRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.srv.StartFailedUsersRetry(req.Limit); err != nil {
+		if errors.Is(err, errors_.ErrRetryInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"response": "retrying failed users"})
+}
+
+// ListAccounts godoc
+// @Summary     List users by account status
+// @Description Returns stored users in one account lifecycle state. A user LeetCode stops returning goes missing, is deleted after the configured number of misses in a row, or is linked to the account that took over its slug or avatar as renamed. Only active and missing users are ranked.
+// @Tags        accounts
+// @Accept      json
+// @Produce     json
+// @Param       page     query    int    true  "Page number (1-based)"
+// @Param       limit    query    int    true  "Page size (1–100)"
+// @Param       status   query    string false "active, missing (default), renamed or deleted"
+// @Success     200      {object} dto.ListAccountsResponse "Users"
+// @Failure     400      {object} map[string]string        "Validation message"
+// @Failure     500      {object} map[string]string        "Internal server error"
+// @Router      /api/v1/accounts [get]
+func (h *Handler) ListAccounts(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req dto.ListAccountsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = service.AccountMissing
+	}
+
+	response, err := h.srv.ListAccounts(ctx, &users_storage.ListUsersByAccountStatusParams{
+		AccountStatus: req.Status,
+		Limit:         int32(req.Limit),
+		Offset:        int32((req.Page - 1) * req.Limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	response.PageLimit = req.PageLimit
+	c.JSON(http.StatusOK, response)
 }
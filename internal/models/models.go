@@ -1,5 +1,10 @@
 package models
 
+import (
+	"database/sql"
+	"strconv"
+)
+
 type StageUserDataParams struct {
 	Username            string
 	UserSlug            string
@@ -10,4 +15,40 @@ type StageUserDataParams struct {
 	Typename            string
 	TotalProblemsSolved int32
 	TotalSubmissions    int32
+	ContestRating       sql.NullFloat64
+	GlobalRanking       sql.NullInt32
+	ContestRankings     sql.NullString
+	DataRegion          sql.NullString
+	EasySolved          int32
+	MediumSolved        int32
+	HardSolved          int32
+	EasySubmissions     int32
+	MediumSubmissions   int32
+	HardSubmissions     int32
+	AllSubmissions      int32
+}
+
+// Kinds of UserChangeEvent, persisted in user_change_events.kind
+const (
+	ChangeEventNewUser         = "new_user"
+	ChangeEventSolvedIncreased = "solved_increased"
+	ChangeEventRatingChanged   = "rating_changed"
+	ChangeEventCountryChanged  = "country_changed"
+)
+
+// UserChangeEvent is a change a user_data merge made to one user
+type UserChangeEvent struct {
+	Username string
+	Kind     string
+	OldValue string // empty for a new user
+	NewValue string // for a new user, its solved count, empty when stats were not fetched yet
+}
+
+// FormatRating renders a contest rating as change events and dry-run reports
+// show it, empty when the user has none
+func FormatRating(v sql.NullFloat64) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(v.Float64, 'f', -1, 64)
 }
@@ -0,0 +1,299 @@
+// Package outbox delivers the user change events queued in event_outbox.
+//
+// Storage.UpsertUserData writes an outbox row in the same transaction as the
+// change it describes, so no committed change is lost and no rolled back one
+// is announced. A Dispatcher then hands every event to its sinks with
+// at-least-once semantics: an event is marked delivered only after every sink
+// accepted it, and is otherwise retried with exponential backoff until
+// OutboxConfig.MaxAttempts marks it dead. A sink can therefore see an event
+// more than once and should deduplicate on Event.ID. The events of one user
+// reach the sinks in the order they happened.
+//
+// Events are only queued while a sink is configured (OutboxConfig.Enabled):
+// nothing else would ever claim them, and a sink enabled later should not be
+// flooded with stale changes. Every dispatcher, with or without sinks, deletes
+// change events older than OutboxConfig.Retention once they are delivered or
+// dead, so neither table grows without bound.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	logger "github.com/ruziba3vich/prodonik_lgger"
+)
+
+// Outbox statuses persisted in event_outbox.status
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+const (
+	storageTimeout = 5 * time.Second
+	pruneInterval  = time.Hour
+	maxErrorLength = 1000
+)
+
+// Event is a user change event as handed to sinks
+type Event struct {
+	ID        int64     `json:"id"` // user_change_events.id, the same on every redelivery
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"` // one of the models.ChangeEvent* kinds
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempt   int32     `json:"attempt"` // 1 on the first delivery
+}
+
+// Sink is where events go: a webhook, a bot, a digest builder. Deliver returns
+// nil once the event is safely handled; an error has the event retried later,
+// for every sink.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, event Event) error
+}
+
+// Dispatcher polls event_outbox and delivers due events to its sinks. Every
+// replica can run one: events are leased, so each is handed to one dispatcher
+// at a time, and a lease left behind by a stopped replica expires.
+type Dispatcher struct {
+	storage users_storage.Querier
+	logger  *logger.Logger
+	cfg     config.OutboxConfig
+	owner   string
+	sinks   []Sink
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a dispatcher that claims events as owner, which must be unique
+// per process
+func New(storage users_storage.Querier, log *logger.Logger, cfg config.OutboxConfig, owner string, sinks ...Sink) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		storage: storage,
+		logger:  log,
+		cfg:     cfg,
+		owner:   owner,
+		sinks:   sinks,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start starts the polling loop. Without sinks it only prunes old events.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	d.wg.Add(1)
+	go d.loop()
+
+	if len(d.sinks) == 0 {
+		d.logger.Info("outbox: no sinks configured, events are not delivered")
+		return nil
+	}
+
+	names := make([]string, len(d.sinks))
+	for i, sink := range d.sinks {
+		names[i] = sink.Name()
+	}
+
+	d.logger.Info("outbox dispatcher started", map[string]any{"sinks": names})
+	return nil
+}
+
+// Stop stops claiming events and waits for the deliveries in flight. A delivery
+// cut short is not recorded; its lease expires and the event is claimed again.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for outbox deliveries: %w", ctx.Err())
+	}
+}
+
+func (d *Dispatcher) loop() {
+	defer d.wg.Done()
+
+	var pruned time.Time
+	for {
+		var claimed int
+		if len(d.sinks) > 0 {
+			claimed = d.dispatch()
+		}
+
+		if time.Since(pruned) >= pruneInterval {
+			d.prune()
+			pruned = time.Now()
+		}
+
+		// a full batch means more events are probably due already
+		wait := d.cfg.PollInterval
+		if claimed >= d.cfg.BatchSize {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch claims a batch of due events and delivers them, at most cfg.Workers
+// at a time. A batch never holds two events of the same user, so delivering
+// them concurrently keeps each user's events in order.
+func (d *Dispatcher) dispatch() int {
+	ctx, cancel := context.WithTimeout(d.ctx, storageTimeout)
+	rows, err := d.storage.ClaimOutboxEvents(ctx, users_storage.ClaimOutboxEventsParams{
+		LimitCount: int32(d.cfg.BatchSize),
+		Owner:      d.owner,
+		TtlSeconds: int32(d.cfg.LeaseTTL.Seconds()),
+	})
+	cancel()
+	if err != nil {
+		if d.ctx.Err() == nil {
+			d.logger.Errorf("outbox: claim events: %v", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(d.cfg.Workers, 1))
+	for _, row := range rows {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(row users_storage.ClaimOutboxEventsRow) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.deliver(row)
+		}(row)
+	}
+	wg.Wait()
+	return len(rows)
+}
+
+// deliver hands one claimed event to every sink and records the outcome
+func (d *Dispatcher) deliver(row users_storage.ClaimOutboxEventsRow) {
+	event := Event{
+		ID:        row.EventID,
+		Username:  row.Username,
+		Kind:      row.Kind,
+		OldValue:  row.OldValue,
+		NewValue:  row.NewValue,
+		CreatedAt: row.CreatedAt,
+		Attempt:   row.Attempts,
+	}
+
+	// finish within the lease, or another dispatcher may deliver it as well
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.LeaseTTL)
+	var deliverErr error
+	for _, sink := range d.sinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			deliverErr = fmt.Errorf("%s: %w", sink.Name(), err)
+			break
+		}
+	}
+	cancel()
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	if deliverErr == nil {
+		n, err := d.storage.MarkOutboxEventDelivered(ctx, users_storage.MarkOutboxEventDeliveredParams{
+			ID:    row.ID,
+			Owner: d.owner,
+		})
+		if err != nil {
+			d.logger.Errorf("outbox: mark event %d delivered: %v", event.ID, err)
+		} else if n == 0 {
+			d.logger.Warn("outbox: lease lost before the event was marked delivered", map[string]any{"event_id": event.ID})
+		}
+		return
+	}
+
+	status, err := d.storage.RetryOutboxEvent(ctx, users_storage.RetryOutboxEventParams{
+		MaxAttempts:       int32(d.cfg.MaxAttempts),
+		RetryAfterSeconds: int32(math.Ceil(d.retryDelay(row.Attempts).Seconds())),
+		LastError:         truncate(deliverErr.Error(), maxErrorLength),
+		ID:                row.ID,
+		Owner:             d.owner,
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		d.logger.Warn("outbox: lease lost before the failed delivery was recorded", map[string]any{"event_id": event.ID})
+	case err != nil:
+		d.logger.Errorf("outbox: record failed delivery of event %d: %v", event.ID, err)
+	case status == StatusDead:
+		d.logger.Error("outbox: giving up on event", map[string]any{
+			"event_id": event.ID,
+			"username": event.Username,
+			"attempts": row.Attempts,
+			"error":    deliverErr.Error(),
+		})
+	default:
+		d.logger.Warnf("outbox: delivery of event %d failed (attempt %d): %v", event.ID, row.Attempts, deliverErr)
+	}
+}
+
+// retryDelay doubles cfg.RetryBaseDelay with every attempt, up to cfg.RetryMaxDelay
+func (d *Dispatcher) retryDelay(attempt int32) time.Duration {
+	delay := d.cfg.RetryBaseDelay
+	for i := int32(1); i < attempt && delay < d.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.RetryMaxDelay)
+}
+
+// prune deletes the change events older than cfg.Retention that are no longer
+// pending delivery
+func (d *Dispatcher) prune() {
+	if d.cfg.Retention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, storageTimeout)
+	defer cancel()
+
+	n, err := d.storage.DeleteExpiredChangeEvents(ctx, time.Now().Add(-d.cfg.Retention))
+	if err != nil {
+		if d.ctx.Err() == nil {
+			d.logger.Errorf("outbox: prune change events: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		d.logger.Info("outbox: pruned change events", map[string]any{"deleted": n})
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,383 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	logger "github.com/ruziba3vich/prodonik_lgger"
+)
+
+// outboxRow is one event_outbox row joined with its user_change_events row
+type outboxRow struct {
+	users_storage.ClaimOutboxEventsRow
+	status         string
+	nextAttemptAt  time.Time
+	owner          string
+	leaseExpiresAt time.Time
+	retryAfter     int32
+}
+
+// outboxStore is an in-memory stand-in for the outbox queries, following what
+// their SQL does
+type outboxStore struct {
+	users_storage.Querier
+
+	mu     sync.Mutex
+	rows   []*outboxRow // in id order
+	claims int
+	prunes int
+}
+
+func (s *outboxStore) add(username string) *outboxRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := int64(len(s.rows) + 1)
+	row := &outboxRow{
+		ClaimOutboxEventsRow: users_storage.ClaimOutboxEventsRow{
+			ID:        id,
+			EventID:   id,
+			Username:  username,
+			Kind:      "solved_increased",
+			CreatedAt: time.Now(),
+		},
+		status: StatusPending,
+	}
+	s.rows = append(s.rows, row)
+	return row
+}
+
+func (s *outboxStore) get(id int64) outboxRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.rows[id-1]
+}
+
+func (s *outboxStore) ClaimOutboxEvents(ctx context.Context, arg users_storage.ClaimOutboxEventsParams) ([]users_storage.ClaimOutboxEventsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims++
+
+	now := time.Now()
+	blocked := make(map[string]bool) // users with an earlier pending event
+	var out []users_storage.ClaimOutboxEventsRow
+	for _, row := range s.rows {
+		if row.status != StatusPending {
+			continue
+		}
+		due := !row.nextAttemptAt.After(now) && (row.leaseExpiresAt.IsZero() || row.leaseExpiresAt.Before(now))
+		if due && !blocked[row.Username] && len(out) < int(arg.LimitCount) {
+			row.owner = arg.Owner
+			row.leaseExpiresAt = now.Add(time.Duration(arg.TtlSeconds) * time.Second)
+			row.Attempts++
+			out = append(out, row.ClaimOutboxEventsRow)
+		}
+		blocked[row.Username] = true
+	}
+	return out, nil
+}
+
+func (s *outboxStore) MarkOutboxEventDelivered(ctx context.Context, arg users_storage.MarkOutboxEventDeliveredParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := s.rows[arg.ID-1]
+	if row.owner != arg.Owner || row.status != StatusPending {
+		return 0, nil
+	}
+	row.status = StatusDelivered
+	row.leaseExpiresAt = time.Time{}
+	return 1, nil
+}
+
+func (s *outboxStore) RetryOutboxEvent(ctx context.Context, arg users_storage.RetryOutboxEventParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := s.rows[arg.ID-1]
+	if row.owner != arg.Owner || row.status != StatusPending {
+		return "", sql.ErrNoRows
+	}
+	if row.Attempts >= arg.MaxAttempts {
+		row.status = StatusDead
+	}
+	row.retryAfter = arg.RetryAfterSeconds
+	row.nextAttemptAt = time.Now().Add(time.Duration(arg.RetryAfterSeconds) * time.Second)
+	row.leaseExpiresAt = time.Time{}
+	return row.status, nil
+}
+
+func (s *outboxStore) DeleteExpiredChangeEvents(ctx context.Context, createdBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prunes++
+	return 0, nil
+}
+
+// makeDue lets a scheduled retry be claimed right away
+func (s *outboxStore) makeDue(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[id-1].nextAttemptAt = time.Time{}
+}
+
+// testSink records the events it is handed and fails those fail returns an
+// error for
+type testSink struct {
+	mu        sync.Mutex
+	delivered []Event
+	fail      func(Event) error
+}
+
+func (s *testSink) Name() string { return "test" }
+
+func (s *testSink) Deliver(ctx context.Context, event Event) error {
+	if s.fail != nil {
+		if err := s.fail(event); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered = append(s.delivered, event)
+	return nil
+}
+
+func (s *testSink) ids() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]int64, len(s.delivered))
+	for i, event := range s.delivered {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func testConfig() config.OutboxConfig {
+	return config.OutboxConfig{
+		BatchSize:      10,
+		Workers:        4,
+		PollInterval:   10 * time.Millisecond,
+		LeaseTTL:       time.Second,
+		MaxAttempts:    3,
+		RetryBaseDelay: 2 * time.Second,
+		RetryMaxDelay:  10 * time.Second,
+		Retention:      time.Hour,
+	}
+}
+
+func newTestDispatcher(t *testing.T, store *outboxStore, cfg config.OutboxConfig, sinks ...Sink) *Dispatcher {
+	t.Helper()
+	log, err := logger.NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	d := New(store, log, cfg, "test-owner", sinks...)
+	t.Cleanup(d.cancel)
+	return d
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := &Dispatcher{cfg: testConfig()}
+	want := map[int32]time.Duration{
+		1:  2 * time.Second,
+		2:  4 * time.Second,
+		3:  8 * time.Second,
+		4:  10 * time.Second,
+		50: 10 * time.Second,
+	}
+	for attempt, delay := range want {
+		if got := d.retryDelay(attempt); got != delay {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, delay)
+		}
+	}
+}
+
+func TestDispatcher_DeliversEachUsersEventsInOrder(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice") // 1
+	store.add("alice") // 2
+	store.add("bob")   // 3
+	sink := &testSink{}
+	d := newTestDispatcher(t, store, testConfig(), sink)
+
+	// alice's second event waits until her first is delivered
+	if n := d.dispatch(); n != 2 {
+		t.Fatalf("first batch claimed %d events, want 2", n)
+	}
+	if n := d.dispatch(); n != 1 {
+		t.Fatalf("second batch claimed %d events, want 1", n)
+	}
+	if n := d.dispatch(); n != 0 {
+		t.Fatalf("third batch claimed %d events, want 0", n)
+	}
+
+	ids := sink.ids()
+	if len(ids) != 3 || ids[2] != 2 {
+		t.Errorf("delivered %v, want alice's event 2 last", ids)
+	}
+	for id := int64(1); id <= 3; id++ {
+		if got := store.get(id).status; got != StatusDelivered {
+			t.Errorf("event %d is %s, want %s", id, got, StatusDelivered)
+		}
+	}
+}
+
+func TestDispatcher_FailedDeliveryIsRetried(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice") // 1
+	store.add("alice") // 2
+	var calls int
+	sink := &testSink{fail: func(e Event) error {
+		if calls++; calls == 1 {
+			return errors.New("receiver down")
+		}
+		return nil
+	}}
+	cfg := testConfig()
+	d := newTestDispatcher(t, store, cfg, sink)
+
+	d.dispatch()
+	row := store.get(1)
+	if row.status != StatusPending || row.Attempts != 1 {
+		t.Fatalf("event 1 is %s after %d attempts, want pending after 1", row.status, row.Attempts)
+	}
+	if want := int32(cfg.RetryBaseDelay.Seconds()); row.retryAfter != want {
+		t.Errorf("retry after %ds, want %ds", row.retryAfter, want)
+	}
+
+	// the failed event is not due yet and still holds back alice's next one
+	if n := d.dispatch(); n != 0 {
+		t.Fatalf("claimed %d events before the retry was due", n)
+	}
+
+	store.makeDue(1)
+	d.dispatch()
+	d.dispatch()
+	if ids := sink.ids(); !equalIDs(ids, []int64{1, 2}) {
+		t.Fatalf("delivered %v, want [1 2]", ids)
+	}
+	if got := sink.delivered[0].Attempt; got != 2 {
+		t.Errorf("retried event handed out as attempt %d, want 2", got)
+	}
+}
+
+func TestDispatcher_DeadEventReleasesLaterEvents(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice") // 1
+	store.add("alice") // 2
+	sink := &testSink{fail: func(e Event) error {
+		if e.ID == 1 {
+			return errors.New("rejected")
+		}
+		return nil
+	}}
+	cfg := testConfig()
+	cfg.MaxAttempts = 2
+	d := newTestDispatcher(t, store, cfg, sink)
+
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		d.dispatch()
+		store.makeDue(1)
+	}
+	if got := store.get(1).status; got != StatusDead {
+		t.Fatalf("event 1 is %s after %d failed attempts, want %s", got, cfg.MaxAttempts, StatusDead)
+	}
+
+	d.dispatch()
+	if ids := sink.ids(); !equalIDs(ids, []int64{2}) {
+		t.Errorf("delivered %v, want [2]", ids)
+	}
+}
+
+func TestDispatcher_LostLeaseIsNotRecorded(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice") // 1
+	store.add("bob")   // 2
+	// another dispatcher takes both leases over while they are being delivered
+	steal := func(e Event) {
+		store.mu.Lock()
+		store.rows[e.ID-1].owner = "other-owner"
+		store.mu.Unlock()
+	}
+	sink := &testSink{fail: func(e Event) error {
+		steal(e)
+		if e.ID == 2 {
+			return errors.New("receiver down")
+		}
+		return nil
+	}}
+	d := newTestDispatcher(t, store, testConfig(), sink)
+
+	d.dispatch()
+	for id := int64(1); id <= 2; id++ {
+		row := store.get(id)
+		if row.status != StatusPending || row.owner != "other-owner" || row.retryAfter != 0 {
+			t.Errorf("event %d was settled by a dispatcher that lost its lease: %+v", id, row)
+		}
+	}
+}
+
+func TestDispatcher_ExpiredLeaseIsDeliveredAgain(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice")
+	// a replica claims the event and stops before settling it
+	if _, err := store.ClaimOutboxEvents(context.Background(), users_storage.ClaimOutboxEventsParams{
+		LimitCount: 1,
+		Owner:      "stopped-owner",
+		TtlSeconds: 0,
+	}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	sink := &testSink{}
+	d := newTestDispatcher(t, store, testConfig(), sink)
+	d.dispatch()
+
+	if len(sink.delivered) != 1 || sink.delivered[0].Attempt != 2 {
+		t.Fatalf("delivered %+v, want the event once as attempt 2", sink.delivered)
+	}
+	if got := store.get(1).status; got != StatusDelivered {
+		t.Errorf("event is %s, want %s", got, StatusDelivered)
+	}
+}
+
+func TestDispatcher_WithoutSinksOnlyPrunes(t *testing.T) {
+	store := &outboxStore{}
+	store.add("alice")
+	d := newTestDispatcher(t, store, testConfig())
+
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := d.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.claims != 0 {
+		t.Errorf("claimed events %d times without sinks", store.claims)
+	}
+	if store.prunes != 1 {
+		t.Errorf("pruned %d times, want 1", store.prunes)
+	}
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	logger "github.com/ruziba3vich/prodonik_lgger"
+)
+
+// LogSink writes every event to the log
+type LogSink struct {
+	logger *logger.Logger
+}
+
+func NewLogSink(log *logger.Logger) *LogSink {
+	return &LogSink{logger: log}
+}
+
+func (s *LogSink) Name() string { return "log" }
+
+func (s *LogSink) Deliver(ctx context.Context, event Event) error {
+	s.logger.Info("user change event", map[string]any{
+		"event_id":  event.ID,
+		"username":  event.Username,
+		"kind":      event.Kind,
+		"old_value": event.OldValue,
+		"new_value": event.NewValue,
+	})
+	return nil
+}
+
+// WebhookSink POSTs every event as JSON to a URL. A 2xx response acknowledges
+// the event; any other response, or none, has it retried. The event ID is also
+// sent in the X-Event-Id header, for receivers that deduplicate.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(event.ID, 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,289 @@
+// Package cassette records HTTP exchanges to files and replays them offline.
+// Set a Transport as an http.Client's transport: in record mode every request
+// goes to the network and the exchange is saved to the cassette directory, one
+// file per distinct request; in replay mode the saved response is served and a
+// request that was never recorded fails with ErrNoRecording.
+//
+// Response bodies are stored decompressed and only a few stable headers are
+// kept, so cassettes can be reviewed and diffed like any golden file.
+package cassette
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+type Mode string
+
+const (
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+// ErrNoRecording is returned in replay mode for a request the cassette does not hold
+var ErrNoRecording = errors.New("cassette: request was not recorded")
+
+// keptHeaders are the headers saved with an exchange; the rest (dates, cookies,
+// CDN and tracing ids, encodings, lengths) change on every run
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+// operationName picks the GraphQL operation out of a query, for readable file names
+var operationName = regexp.MustCompile(`^\s*(?:query|mutation)\s+(\w+)`)
+
+// Exchange is one recorded request and its response, as stored on disk
+type Exchange struct {
+	Request  Message `json:"request"`
+	Response Message `json:"response"`
+}
+
+// Message is a request or response. JSON bodies are kept as JSON, anything else
+// (including JSON that does not parse) as text.
+type Message struct {
+	Method   string              `json:"method,omitempty"`
+	URL      string              `json:"url,omitempty"`
+	Status   int                 `json:"status,omitempty"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Body     json.RawMessage     `json:"body,omitempty"`
+	BodyText string              `json:"body_text,omitempty"`
+}
+
+// Transport is an http.RoundTripper that records to or replays from dir
+type Transport struct {
+	mode Mode
+	dir  string
+	next http.RoundTripper // the network, in record mode
+
+	mu sync.Mutex // serialises cassette writes
+}
+
+// New returns a transport for mode. next carries recorded requests and defaults
+// to http.DefaultTransport; replay never touches it.
+func New(mode Mode, dir string, next http.RoundTripper) (*Transport, error) {
+	switch mode {
+	case ModeRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("cassette: create %s: %w", dir, err)
+		}
+	case ModeReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q, use %q or %q", mode, ModeRecord, ModeReplay)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: mode, dir: dir, next: next}, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cassette: read request body: %w", err)
+		}
+	}
+	path := filepath.Join(t.dir, fileName(req, body))
+
+	if t.mode == ModeReplay {
+		return t.replay(req, body, path)
+	}
+	return t.record(req, body, path)
+}
+
+func (t *Transport) replay(req *http.Request, body []byte, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s %s (expected %s)", ErrNoRecording, req.Method, req.URL, truncate(string(body), 200), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+
+	var ex Exchange
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, fmt.Errorf("cassette: decode %s: %w", path, err)
+	}
+	return newResponse(req, ex.Response), nil
+}
+
+func (t *Transport) record(req *http.Request, body []byte, path string) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := decompress(resp)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: decompress response: %w", err)
+	}
+
+	ex := Exchange{
+		Request: Message{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: normalizeHeaders(req.Header),
+		},
+		Response: Message{
+			Status:  resp.StatusCode,
+			Headers: normalizeHeaders(resp.Header),
+		},
+	}
+	ex.Request.setBody(body)
+	ex.Response.setBody(respBody)
+	if err := t.save(path, ex); err != nil {
+		return nil, err
+	}
+	return newResponse(req, ex.Response), nil
+}
+
+func (t *Transport) save(path string, ex Exchange) error {
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: encode exchange: %w", err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	return nil
+}
+
+// fileName identifies a request by method, URL and body, with JSON bodies
+// compared by value so key order and spacing do not matter
+func fileName(req *http.Request, body []byte) string {
+	normalized := body
+	var v any
+	if json.Unmarshal(body, &v) == nil {
+		normalized, _ = json.Marshal(v)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL)
+	h.Write(normalized)
+	sum := hex.EncodeToString(h.Sum(nil))[:16]
+
+	name := strings.ToLower(req.Method)
+	var gql struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(body, &gql) == nil {
+		if m := operationName.FindStringSubmatch(gql.Query); m != nil {
+			name = m[1]
+		}
+	}
+	return name + "-" + sum + ".json"
+}
+
+func (m *Message) setBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if json.Valid(body) {
+		var buf bytes.Buffer
+		if json.Indent(&buf, body, "", "  ") == nil {
+			m.Body = buf.Bytes()
+			return
+		}
+	}
+	m.BodyText = string(body)
+}
+
+func (m Message) body() []byte {
+	if len(m.Body) > 0 {
+		var buf bytes.Buffer
+		if json.Compact(&buf, m.Body) == nil {
+			return buf.Bytes()
+		}
+		return m.Body
+	}
+	return []byte(m.BodyText)
+}
+
+func newResponse(req *http.Request, m Message) *http.Response {
+	body := m.body()
+	header := make(http.Header, len(m.Headers))
+	for k, v := range m.Headers {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", m.Status, http.StatusText(m.Status)),
+		StatusCode:    m.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// normalizeHeaders keeps keptHeaders in canonical form, values sorted
+func normalizeHeaders(h http.Header) map[string][]string {
+	out := make(map[string][]string)
+	for _, k := range keptHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			v = append([]string(nil), v...)
+			sort.Strings(v)
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func decompress(resp *http.Response) ([]byte, error) {
+	var reader io.Reader = resp.Body
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		reader = gr
+	case "br":
+		reader = brotli.NewReader(resp.Body)
+	case "deflate":
+		fr := flate.NewReader(resp.Body)
+		defer fr.Close()
+		reader = fr
+	}
+	return io.ReadAll(reader)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
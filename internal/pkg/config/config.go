@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -17,8 +18,96 @@ type PostgresConfig struct {
 }
 
 type LeetcodeClientConfig struct {
-	Delay time.Duration
 	Debug bool
+	URL   string // GraphQL endpoint, point it at cmd/fakeleetcode for local runs
+	// CassetteMode records every LeetCode exchange to CassetteDir ("record") or
+	// serves them from there without network access ("replay"); empty disables it
+	CassetteMode string
+	CassetteDir  string
+	// shared request budget for every call to LeetCode
+	RequestsPerSecond    float64
+	Burst                int
+	MinRequestsPerSecond float64 // floor the limiter may slow down to when throttled
+	RecoverAfter         int     // successful responses before the limiter speeds up again
+	// retry budgets (total attempts) per call site
+	PageMaxAttempts        int // ranking pages during sync
+	UserMaxAttempts        int // user profiles during sync
+	InteractiveMaxAttempts int // CreateUser / GetUserData, a client is waiting
+	RetryBaseDelay         time.Duration
+	RetryMaxDelay          time.Duration
+	UserBatchSize          int // users per aliased GraphQL query, 1 disables batching
+}
+
+// FailedUsersConfig controls the dead-letter queue of users whose fetch failed
+type FailedUsersConfig struct {
+	MaxAttempts    int // attempts before a user is marked permanently failed
+	RetryBatchSize int // pending users re-fetched per pass
+	Workers        int
+}
+
+// AccountsConfig controls how accounts LeetCode stops returning are retired
+type AccountsConfig struct {
+	MissesToDelete int           // not-found fetches in a row before an account is confirmed deleted
+	MissInterval   time.Duration // misses closer together than this count once
+}
+
+// SchedulerConfig holds the default cron specs (standard 5-field or @descriptor)
+// of the recurring jobs. They seed sync_schedules and can be changed through the
+// API afterwards. An empty spec registers the job disabled.
+type SchedulerConfig struct {
+	FullSync        string
+	PriorityRefresh string
+	FailedUsers     string
+	SyncWorkers     int
+	SyncMode        string        // full, incremental or ranking, for the full_sync schedule
+	SyncMaxAge      time.Duration // incremental: refresh users older than this regardless
+	// SyncDistributed leases the full_sync pages to every replica instead of
+	// sweeping them on the one that claimed the run. A lone replica works every
+	// lease itself, so it is on by default.
+	SyncDistributed bool
+}
+
+// RefreshTiersConfig sets how often each priority tier is refreshed by the
+// priority_refresh schedule. A zero interval leaves the tier to the page sweep.
+type RefreshTiersConfig struct {
+	ManualInterval   time.Duration // users added through /add-user
+	TrackedInterval  time.Duration // members of a tracked group
+	TopNInterval     time.Duration // top TopN solvers of each country
+	LongTailInterval time.Duration // everyone else
+	TopN             int
+	BatchSize        int // users re-fetched per pass
+}
+
+// LeaseConfig controls how replicas share a distributed sync through sync_page_leases
+type LeaseConfig struct {
+	ReplicaID     string // owner written on claimed leases, unique per process
+	PagesPerLease int
+	TTL           time.Duration // a lease not renewed for this long may be taken over
+	Heartbeat     time.Duration
+	PollInterval  time.Duration // how often an idle replica looks for claimable leases
+	MaxAttempts   int           // claims of a lease before its page range is given up
+}
+
+// OutboxConfig controls delivery of user change events from event_outbox.
+// Events are only queued in the outbox while a sink is configured, so a sink
+// enabled later starts with the changes made from then on, not a backlog.
+type OutboxConfig struct {
+	WebhookURL     string // events are POSTed here as JSON, empty for no webhook
+	WebhookTimeout time.Duration
+	LogEvents      bool // also write every event to the log
+	BatchSize      int  // events claimed per poll
+	Workers        int  // events delivered at once, each of a different user
+	PollInterval   time.Duration
+	LeaseTTL       time.Duration // a claimed event not settled for this long is claimed again
+	MaxAttempts    int           // claims of an event before it is marked dead
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	Retention      time.Duration // change events no longer pending delivery are deleted after this long
+}
+
+// Enabled reports whether any sink is configured
+func (c OutboxConfig) Enabled() bool {
+	return c.WebhookURL != "" || c.LogEvents
 }
 
 type Config struct {
@@ -27,6 +116,12 @@ type Config struct {
 	TgBotToken  string
 	AppPort     string
 	LeetcodeClientConfig
+	FailedUsers  FailedUsersConfig
+	Accounts     AccountsConfig
+	Scheduler    SchedulerConfig
+	RefreshTiers RefreshTiersConfig
+	Leases       LeaseConfig
+	Outbox       OutboxConfig
 }
 
 // Load reads configuration from environment variables
@@ -50,8 +145,68 @@ func Load() *Config {
 		TgBotToken:  getEnv("TG_BOT_TOKEN", "8256069245:AAG9R6mTbOd3K_IGCaGeCSEBB-FZSE4cWVA"),
 		AppPort:     getEnv("APP_PORT", "8888"),
 		LeetcodeClientConfig: LeetcodeClientConfig{
-			Delay: getTimeEnv("LEETCODE_CLIENT_DELAY", 800, time.Millisecond),
-			Debug: true,
+			Debug:                true,
+			URL:                  getEnv("LEETCODE_URL", "https://leetcode.com/graphql"),
+			CassetteMode:         getEnv("LEETCODE_CASSETTE_MODE", ""),
+			CassetteDir:          getEnv("LEETCODE_CASSETTE_DIR", "tests/cassettes"),
+			RequestsPerSecond:    getFloatEnv("LEETCODE_RPS", 2),
+			Burst:                getIntEnv("LEETCODE_BURST", 4),
+			MinRequestsPerSecond: getFloatEnv("LEETCODE_MIN_RPS", 0.2),
+			RecoverAfter:         getIntEnv("LEETCODE_RECOVER_AFTER", 20),
+
+			PageMaxAttempts:        getIntEnv("LEETCODE_PAGE_MAX_ATTEMPTS", 6),
+			UserMaxAttempts:        getIntEnv("LEETCODE_USER_MAX_ATTEMPTS", 4),
+			InteractiveMaxAttempts: getIntEnv("LEETCODE_INTERACTIVE_MAX_ATTEMPTS", 2),
+			RetryBaseDelay:         getTimeEnv("LEETCODE_RETRY_BASE_DELAY_MS", 500, time.Millisecond),
+			RetryMaxDelay:          getTimeEnv("LEETCODE_RETRY_MAX_DELAY_MS", 30000, time.Millisecond),
+			UserBatchSize:          getIntEnv("LEETCODE_USER_BATCH_SIZE", 10),
+		},
+		FailedUsers: FailedUsersConfig{
+			MaxAttempts:    getIntEnv("FAILED_USERS_MAX_ATTEMPTS", 5),
+			RetryBatchSize: getIntEnv("FAILED_USERS_RETRY_BATCH", 200),
+			Workers:        getIntEnv("FAILED_USERS_WORKERS", 3),
+		},
+		Accounts: AccountsConfig{
+			MissesToDelete: getIntEnv("ACCOUNT_MISSES_TO_DELETE", 3),
+			MissInterval:   getTimeEnv("ACCOUNT_MISS_INTERVAL_HOURS", 24, time.Hour),
+		},
+		Scheduler: SchedulerConfig{
+			FullSync:        getEnv("SCHEDULE_FULL_SYNC", "0 0 * * *"),
+			PriorityRefresh: getEnv("SCHEDULE_PRIORITY_REFRESH", "*/5 * * * *"),
+			FailedUsers:     getEnv("SCHEDULE_FAILED_USERS", "*/15 * * * *"),
+			SyncWorkers:     getIntEnv("SCHEDULE_SYNC_WORKERS", 4),
+			SyncMode:        getEnv("SCHEDULE_SYNC_MODE", "incremental"),
+			SyncMaxAge:      getTimeEnv("SCHEDULE_SYNC_MAX_AGE_HOURS", 168, time.Hour),
+			SyncDistributed: getBoolEnv("SCHEDULE_SYNC_DISTRIBUTED", true),
+		},
+		RefreshTiers: RefreshTiersConfig{
+			ManualInterval:   getTimeEnv("REFRESH_MANUAL_INTERVAL_MIN", 30, time.Minute),
+			TrackedInterval:  getTimeEnv("REFRESH_TRACKED_INTERVAL_MIN", 60, time.Minute),
+			TopNInterval:     getTimeEnv("REFRESH_TOP_N_INTERVAL_MIN", 360, time.Minute),
+			LongTailInterval: getTimeEnv("REFRESH_LONG_TAIL_INTERVAL_MIN", 0, time.Minute),
+			TopN:             getIntEnv("REFRESH_TOP_N", 100),
+			BatchSize:        getIntEnv("REFRESH_BATCH_SIZE", 300),
+		},
+		Leases: LeaseConfig{
+			ReplicaID:     getEnv("SYNC_REPLICA_ID", defaultReplicaID()),
+			PagesPerLease: getIntEnv("SYNC_LEASE_PAGES", 20),
+			TTL:           getTimeEnv("SYNC_LEASE_TTL_SEC", 120, time.Second),
+			Heartbeat:     getTimeEnv("SYNC_LEASE_HEARTBEAT_SEC", 30, time.Second),
+			PollInterval:  getTimeEnv("SYNC_LEASE_POLL_SEC", 10, time.Second),
+			MaxAttempts:   getIntEnv("SYNC_LEASE_MAX_ATTEMPTS", 3),
+		},
+		Outbox: OutboxConfig{
+			WebhookURL:     getEnv("OUTBOX_WEBHOOK_URL", ""),
+			WebhookTimeout: getTimeEnv("OUTBOX_WEBHOOK_TIMEOUT_SEC", 10, time.Second),
+			LogEvents:      getBoolEnv("OUTBOX_LOG_EVENTS", false),
+			BatchSize:      getIntEnv("OUTBOX_BATCH_SIZE", 100),
+			Workers:        getIntEnv("OUTBOX_WORKERS", 8),
+			PollInterval:   getTimeEnv("OUTBOX_POLL_MS", 2000, time.Millisecond),
+			LeaseTTL:       getTimeEnv("OUTBOX_LEASE_TTL_SEC", 60, time.Second),
+			MaxAttempts:    getIntEnv("OUTBOX_MAX_ATTEMPTS", 10),
+			RetryBaseDelay: getTimeEnv("OUTBOX_RETRY_BASE_DELAY_SEC", 5, time.Second),
+			RetryMaxDelay:  getTimeEnv("OUTBOX_RETRY_MAX_DELAY_SEC", 3600, time.Second),
+			Retention:      getTimeEnv("OUTBOX_RETENTION_HOURS", 168, time.Hour),
 		},
 	}
 }
@@ -64,6 +219,39 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getIntEnv(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		valueInt, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		return valueInt
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		valueFloat, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		return valueFloat
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		valueBool, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		return valueBool
+	}
+	return defaultValue
+}
+
 func getTimeEnv(key string, defaultValue int, duration time.Duration) time.Duration {
 	if value, exists := os.LookupEnv(key); exists {
 		valueInt, _ := strconv.Atoi(value)
@@ -72,3 +260,12 @@ func getTimeEnv(key string, defaultValue int, duration time.Duration) time.Durat
 
 	return time.Duration(defaultValue) * duration
 }
+
+// defaultReplicaID tells replicas apart when SYNC_REPLICA_ID is not set
+func defaultReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "replica"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
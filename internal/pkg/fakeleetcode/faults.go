@@ -0,0 +1,134 @@
+package fakeleetcode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// FaultsPath reads (GET) and replaces (PUT) the faults of a running server
+const FaultsPath = "/_fake/faults"
+
+// Response encodings Faults.Encoding can ask for
+const (
+	EncodingIdentity = ""
+	EncodingGzip     = "gzip"
+	EncodingBrotli   = "br"
+	EncodingRandom   = "random" // identity, gzip or br per response
+)
+
+// Faults are what the server does to its responses. Rates are probabilities
+// between 0 and 1, drawn per request.
+type Faults struct {
+	Latency       time.Duration `json:"latency"`        // added to every response
+	Jitter        time.Duration `json:"jitter"`         // up to this much more, at random
+	ThrottleRate  float64       `json:"throttle_rate"`  // answered with 429 Too Many Requests
+	RetryAfter    time.Duration `json:"retry_after"`    // Retry-After of a 429, whole seconds
+	MalformedRate float64       `json:"malformed_rate"` // a 200 whose JSON body is cut in half
+	Encoding      string        `json:"encoding"`
+}
+
+// SetFaults replaces the faults applied from the next request on
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+}
+
+func (s *Server) Faults() Faults {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.faults
+}
+
+// serveFaults lets a running fake be reconfigured, e.g.
+// curl -X PUT localhost:8089/_fake/faults -d '{"throttle_rate": 0.2, "encoding": "br"}'
+// Durations are in nanoseconds, as encoding/json writes time.Duration.
+func (s *Server) serveFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var f Faults
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.SetFaults(f)
+	default:
+		http.Error(w, "use GET or PUT", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Faults(), Faults{})
+}
+
+// delay sleeps for the latency, returning early when the client goes away
+func (f Faults) delay(ctx context.Context) error {
+	d := f.Latency
+	if f.Jitter > 0 {
+		d += rand.N(f.Jitter)
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (f Faults) throttle() bool {
+	return f.ThrottleRate > 0 && rand.Float64() < f.ThrottleRate
+}
+
+func (f Faults) malformed() bool {
+	return f.MalformedRate > 0 && rand.Float64() < f.MalformedRate
+}
+
+func (f Faults) encoding() string {
+	if f.Encoding == EncodingRandom {
+		return []string{EncodingIdentity, EncodingGzip, EncodingBrotli}[rand.N(3)]
+	}
+	return f.Encoding
+}
+
+// writeJSON marshals v, corrupting and compressing it as the faults say
+func writeJSON(w http.ResponseWriter, status int, v any, f Faults) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == http.StatusOK && f.malformed() {
+		body = body[:len(body)/2]
+	}
+
+	var buf bytes.Buffer
+	switch encoding := f.encoding(); encoding {
+	case EncodingGzip:
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+		w.Header().Set("Content-Encoding", encoding)
+	case EncodingBrotli:
+		bw := brotli.NewWriter(&buf)
+		bw.Write(body)
+		bw.Close()
+		body = buf.Bytes()
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,230 @@
+package fakeleetcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// syntheticPrefix names generated users: synthetic_0000000, synthetic_0000001, ...
+const syntheticPrefix = "synthetic_"
+
+type profile struct {
+	UserSlug    string `json:"userSlug"`
+	UserAvatar  string `json:"userAvatar"`
+	CountryCode string `json:"countryCode"`
+	CountryName string `json:"countryName"`
+	RealName    string `json:"realName"`
+	Typename    string `json:"__typename"`
+}
+
+type stat struct {
+	Difficulty  string `json:"difficulty"`
+	Count       int    `json:"count"`
+	Submissions int    `json:"submissions"`
+}
+
+type matchedUser struct {
+	SubmitStats struct {
+		ACSubmissionNum    []stat `json:"acSubmissionNum"`
+		TotalSubmissionNum []stat `json:"totalSubmissionNum"`
+	} `json:"submitStats"`
+	Profile profile `json:"profile"`
+}
+
+// rankingNode is one entry of globalRanking.rankingNodes. Fixture nodes are
+// served exactly as they were recorded.
+type rankingNode struct {
+	raw      json.RawMessage
+	username string
+	profile  profile
+}
+
+// LoadRankingFile appends the rankingNodes of a recorded globalRanking response,
+// such as tests/fetched_first_page_users.json, to the ranking. Users without a
+// profile from LoadUsersFile get generated solved counts.
+func (s *Server) LoadRankingFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read ranking fixture: %w", err)
+	}
+
+	var wrap struct {
+		Data struct {
+			GlobalRanking struct {
+				RankingNodes []json.RawMessage `json:"rankingNodes"`
+			} `json:"globalRanking"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrap); err != nil {
+		return fmt.Errorf("unmarshal ranking fixture %s: %w", path, err)
+	}
+
+	nodes := make([]rankingNode, 0, len(wrap.Data.GlobalRanking.RankingNodes))
+	for _, raw := range wrap.Data.GlobalRanking.RankingNodes {
+		var node struct {
+			User struct {
+				Username string  `json:"username"`
+				Profile  profile `json:"profile"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return fmt.Errorf("unmarshal ranking node in %s: %w", path, err)
+		}
+		nodes = append(nodes, rankingNode{raw: raw, username: node.User.Username, profile: node.User.Profile})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranking = append(s.ranking, nodes...)
+	for _, n := range nodes {
+		if _, ok := s.users[n.username]; !ok {
+			s.users[n.username] = generatedUser(n.username, n.profile)
+		}
+	}
+	return nil
+}
+
+// LoadUsersFile adds the users of a leetcode_users.json style fixture: a list of
+// {"user": {"username", "profile": {..., "totalProblemsSolved", "totalSubmissions"}}}.
+// They answer matchedUser queries but are not ranked.
+func (s *Server) LoadUsersFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read users fixture: %w", err)
+	}
+
+	var entries []struct {
+		User struct {
+			Username string `json:"username"`
+			Profile  struct {
+				profile
+				TotalProblemsSolved int `json:"totalProblemsSolved"`
+				TotalSubmissions    int `json:"totalSubmissions"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("unmarshal users fixture %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		p := e.User.Profile
+		s.users[e.User.Username] = newMatchedUser(p.profile, p.TotalProblemsSolved, p.TotalSubmissions)
+	}
+	return nil
+}
+
+// SetSyntheticUsers ranks n generated users after the fixture ones. They are
+// derived from their index on every request instead of being kept in memory, so
+// millions of them cost nothing.
+func (s *Server) SetSyntheticUsers(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synthetic = max(n, 0)
+}
+
+// newMatchedUser splits solved and submissions over the difficulties roughly
+// like real profiles do
+func newMatchedUser(p profile, solved, submissions int) *matchedUser {
+	easy, medium := solved*4/10, solved*45/100
+	hard := solved - easy - medium
+	easySubs, mediumSubs := submissions*4/10, submissions*45/100
+	hardSubs := submissions - easySubs - mediumSubs
+
+	u := &matchedUser{Profile: p}
+	u.SubmitStats.ACSubmissionNum = []stat{
+		{Difficulty: "All", Count: solved, Submissions: submissions},
+		{Difficulty: "Easy", Count: easy, Submissions: easySubs},
+		{Difficulty: "Medium", Count: medium, Submissions: mediumSubs},
+		{Difficulty: "Hard", Count: hard, Submissions: hardSubs},
+	}
+	// every accepted submission took about one and a half tries
+	u.SubmitStats.TotalSubmissionNum = []stat{
+		{Difficulty: "All", Count: solved, Submissions: submissions * 3 / 2},
+		{Difficulty: "Easy", Count: easy, Submissions: easySubs * 3 / 2},
+		{Difficulty: "Medium", Count: medium, Submissions: mediumSubs * 3 / 2},
+		{Difficulty: "Hard", Count: hard, Submissions: hardSubs * 3 / 2},
+	}
+	return u
+}
+
+// generatedUser gives a ranked user without a recorded profile stable solved counts
+func generatedUser(username string, p profile) *matchedUser {
+	h := fnv.New32a()
+	h.Write([]byte(username))
+	solved := int(h.Sum32()%3000) + 50
+	return newMatchedUser(p, solved, solved+solved/3)
+}
+
+func syntheticUsername(i int) string {
+	return fmt.Sprintf("%s%07d", syntheticPrefix, i)
+}
+
+// syntheticIndex parses the index out of a generated username
+func syntheticIndex(username string) (int, bool) {
+	rest, ok := strings.CutPrefix(username, syntheticPrefix)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(rest)
+	if err != nil || i < 0 || syntheticUsername(i) != username {
+		return 0, false
+	}
+	return i, true
+}
+
+// Countries generated users are spread over
+var syntheticCountries = [][2]string{
+	{"US", "United States"},
+	{"IN", "India"},
+	{"CN", "China"},
+	{"UZ", "Uzbekistan"},
+	{"DE", "Germany"},
+	{"", ""},
+}
+
+func syntheticProfile(i int) profile {
+	username := syntheticUsername(i)
+	country := syntheticCountries[i%len(syntheticCountries)]
+	return profile{
+		UserSlug:    username,
+		UserAvatar:  "https://assets.leetcode.com/users/default_avatar.jpg",
+		CountryCode: country[0],
+		CountryName: country[1],
+		RealName:    fmt.Sprintf("Synthetic User %d", i),
+		Typename:    "UserProfileNode",
+	}
+}
+
+// syntheticUser solves fewer problems the lower it is ranked
+func syntheticUser(i int) *matchedUser {
+	solved := max(3000-i/100, 1)
+	return newMatchedUser(syntheticProfile(i), solved, solved+solved/4)
+}
+
+// syntheticNode is the ranking entry of generated user i, ranked at rank
+func syntheticNode(i, rank int) json.RawMessage {
+	p := syntheticProfile(i)
+	node := map[string]any{
+		"ranking":              "[]",
+		"currentRating":        strconv.FormatFloat(max(3500-float64(i)*0.01, 1200), 'f', 3, 64),
+		"currentGlobalRanking": rank,
+		"dataRegion":           "US",
+		"user": map[string]any{
+			"username":    p.UserSlug,
+			"nameColor":   nil,
+			"activeBadge": nil,
+			"profile":     p,
+			"__typename":  "UserNode",
+		},
+		"__typename": "RankingNode",
+	}
+	raw, _ := json.Marshal(node)
+	return raw
+}
@@ -0,0 +1,186 @@
+// Package fakeleetcode is a stand-in for LeetCode's GraphQL endpoint. It answers
+// the globalRanking and matchedUser queries the service sends, from fixture
+// files and generated users, and can slow down, throttle, corrupt or compress
+// its responses on demand. Server is an http.Handler, so tests can run it with
+// httptest.NewServer; cmd/fakeleetcode serves it for local runs.
+package fakeleetcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultUsersPerPage is the page size of LeetCode's global ranking
+const DefaultUsersPerPage = 25
+
+// matchedUserField finds every (optionally aliased) matchedUser field of a
+// query and the variable holding its username
+var matchedUserField = regexp.MustCompile(`(?:(\w+)\s*:\s*)?matchedUser\s*\(\s*username\s*:\s*\$(\w+)\s*\)`)
+
+type Server struct {
+	mu        sync.RWMutex
+	ranking   []rankingNode           // fixture users, ranked first
+	users     map[string]*matchedUser // profiles by username, ranked or not
+	synthetic int                     // generated users ranked after ranking
+	perPage   int
+	faults    Faults
+
+	requests atomic.Int64
+}
+
+// New returns a server without users; load fixtures or add synthetic users next
+func New() *Server {
+	return &Server{
+		users:   make(map[string]*matchedUser),
+		perPage: DefaultUsersPerPage,
+	}
+}
+
+// SetUsersPerPage changes the ranking page size
+func (s *Server) SetUsersPerPage(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perPage = max(n, 1)
+}
+
+// Requests is the number of GraphQL requests served so far, faulty ones included
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// ServeHTTP answers GraphQL POSTs on any path, except FaultsPath
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == FaultsPath {
+		s.serveFaults(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	s.requests.Add(1)
+
+	faults := s.Faults()
+	if err := faults.delay(r.Context()); err != nil {
+		return
+	}
+	if faults.throttle() {
+		w.Header().Set("Retry-After", fmt.Sprint(int(faults.RetryAfter.Seconds())))
+		http.Error(w, `{"error": "rate limited"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	var req graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []graphQLError{{Message: "invalid request body: " + err.Error()}}}, faults)
+		return
+	}
+
+	var resp map[string]any
+	switch {
+	case strings.Contains(req.Query, "globalRanking"):
+		resp = s.globalRanking(req)
+	case strings.Contains(req.Query, "matchedUser"):
+		resp = s.matchedUsers(req)
+	default:
+		resp = map[string]any{"errors": []graphQLError{{Message: "the fake only knows globalRanking and matchedUser"}}}
+	}
+	writeJSON(w, http.StatusOK, resp, faults)
+}
+
+func (s *Server) globalRanking(req graphQLRequest) map[string]any {
+	page := 1
+	if p, ok := req.Variables["page"].(float64); ok && p >= 1 {
+		page = int(p)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := len(s.ranking) + s.synthetic
+	start := (page - 1) * s.perPage
+	end := min(start+s.perPage, total)
+
+	nodes := []json.RawMessage{}
+	for i := start; i < end; i++ {
+		if i < len(s.ranking) {
+			nodes = append(nodes, s.ranking[i].raw)
+		} else {
+			nodes = append(nodes, syntheticNode(i-len(s.ranking), i+1))
+		}
+	}
+
+	return map[string]any{"data": map[string]any{
+		"globalRanking": map[string]any{
+			"totalUsers":   total,
+			"totalPages":   (total + s.perPage - 1) / s.perPage,
+			"userPerPage":  s.perPage,
+			"rankingNodes": nodes,
+			"__typename":   "GlobalRankingNode",
+		},
+	}}
+}
+
+// matchedUsers answers both the single user query and the aliased batch query.
+// Unknown users come back null with LeetCode's "does not exist" error.
+func (s *Server) matchedUsers(req graphQLRequest) map[string]any {
+	data := map[string]any{}
+	var errs []graphQLError
+
+	if strings.Contains(req.Query, "allQuestionsCount") {
+		data["allQuestionsCount"] = []stat{
+			{Difficulty: "All", Count: 3500},
+			{Difficulty: "Easy", Count: 880},
+			{Difficulty: "Medium", Count: 1840},
+			{Difficulty: "Hard", Count: 780},
+		}
+	}
+
+	for _, m := range matchedUserField.FindAllStringSubmatch(req.Query, -1) {
+		field := m[1]
+		if field == "" {
+			field = "matchedUser"
+		}
+		username, _ := req.Variables[m[2]].(string)
+
+		if user := s.lookup(username); user != nil {
+			data[field] = user
+			continue
+		}
+		data[field] = nil
+		errs = append(errs, graphQLError{Message: "That user does not exist.", Path: []any{field}})
+	}
+
+	resp := map[string]any{"data": data}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	return resp
+}
+
+func (s *Server) lookup(username string) *matchedUser {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if user, ok := s.users[username]; ok {
+		return user
+	}
+	if i, ok := syntheticIndex(username); ok && i < s.synthetic {
+		return syntheticUser(i)
+	}
+	return nil
+}
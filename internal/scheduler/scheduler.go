@@ -0,0 +1,483 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	logger "github.com/ruziba3vich/prodonik_lgger"
+)
+
+// Names of the built-in schedules
+const (
+	FullSync        = "full_sync"
+	PriorityRefresh = "priority_refresh"
+	FailedUsers     = "failed_users"
+)
+
+// Run statuses persisted in schedule_runs.status
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+const storageTimeout = 5 * time.Second
+
+// reloadInterval is how often stored schedules are re-read, so an update served
+// by another replica reaches this one, and how often the runs in progress send
+// a heartbeat
+const reloadInterval = 30 * time.Second
+
+// runStaleAfter is how long a run may go without a heartbeat before it is
+// failed as interrupted, by any replica
+const runStaleAfter = 4 * reloadInterval
+
+// Job is the work behind a schedule. Returning errors_.ErrSyncInProgress or
+// errors_.ErrRetryInProgress records the run as skipped instead of failed.
+type Job func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	job      Job
+	spec     string
+	schedule cron.Schedule
+	enabled  bool
+	next     time.Time
+	running  bool
+}
+
+// Scheduler fires registered jobs on cron schedules stored in sync_schedules.
+// Every replica runs one and fires every schedule, but each run is claimed in
+// schedule_runs first, so only one replica executes it. A schedule never
+// overlaps itself: a run claimed while the previous one is still going, on any
+// replica, is recorded as skipped.
+type Scheduler struct {
+	storage users_storage.Querier
+	logger  *logger.Logger
+	owner   string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	wake    chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a scheduler that records its runs as owner, which must be unique
+// per process
+func New(storage users_storage.Querier, log *logger.Logger, owner string) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		storage: storage,
+		logger:  log,
+		owner:   owner,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Register adds a job with its default spec; an empty spec registers it disabled.
+// The stored schedule, if any, takes precedence over the default on Start.
+func (s *Scheduler) Register(name, defaultSpec string, job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[name] = &entry{name: name, job: job, spec: defaultSpec, enabled: defaultSpec != ""}
+}
+
+// Start seeds missing schedules, loads the stored ones and starts the timer loop
+func (s *Scheduler) Start(ctx context.Context) error {
+	if _, err := s.storage.FailStaleScheduleRuns(ctx, int32(runStaleAfter/time.Second)); err != nil {
+		return fmt.Errorf("close interrupted schedule runs: %w", err)
+	}
+
+	s.mu.Lock()
+	for _, e := range s.entries {
+		if err := s.storage.EnsureSyncSchedule(ctx, users_storage.EnsureSyncScheduleParams{
+			Name:    e.name,
+			Spec:    e.spec,
+			Enabled: e.enabled,
+		}); err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("seed schedule %s: %w", e.name, err)
+		}
+	}
+	s.mu.Unlock()
+
+	stored, err := s.storage.ListSyncSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("load schedules: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for _, row := range stored {
+		e, ok := s.entries[row.Name]
+		if !ok {
+			continue
+		}
+		if err := e.apply(row.Spec, row.Enabled, now); err != nil {
+			s.logger.Errorf("scheduler: schedule %s has an invalid spec %q, disabling it: %v", row.Name, row.Spec, err)
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.loop()
+	s.logger.Info("scheduler started", map[string]any{"schedules": len(stored)})
+	return nil
+}
+
+// Stop stops firing new runs and waits for the running ones to return
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for scheduled runs: %w", ctx.Err())
+	}
+}
+
+// apply must be called with the scheduler mutex held
+func (e *entry) apply(spec string, enabled bool, now time.Time) error {
+	e.spec = spec
+	e.enabled = enabled
+	e.schedule = nil
+	e.next = time.Time{}
+	if !enabled {
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		e.enabled = false
+		return err
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		schedule = alignedDelay{delay: every.Delay}
+	}
+	e.schedule = schedule
+	e.next = schedule.Next(now)
+	return nil
+}
+
+// alignedDelay fires an @every schedule on multiples of its delay instead of
+// relative to when the replica started, so all replicas claim the same runs
+type alignedDelay struct {
+	delay time.Duration
+}
+
+func (a alignedDelay) Next(t time.Time) time.Time {
+	return t.Truncate(a.delay).Add(a.delay)
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	nextReload := time.Now().Add(reloadInterval)
+	for {
+		if !time.Now().Before(nextReload) {
+			s.reload()
+			s.heartbeat()
+			nextReload = time.Now().Add(reloadInterval)
+		}
+
+		s.mu.Lock()
+		next := nextReload
+		for _, e := range s.entries {
+			if e.enabled && e.next.Before(next) {
+				next = e.next
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if !e.enabled || e.next.After(now) {
+				continue
+			}
+			s.dispatch(e, e.next)
+			e.next = e.schedule.Next(now)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// reload applies the stored schedules that differ from the ones in memory, i.e.
+// updates made through another replica's API
+func (s *Scheduler) reload() {
+	ctx, cancel := context.WithTimeout(s.ctx, storageTimeout)
+	defer cancel()
+
+	stored, err := s.storage.ListSyncSchedules(ctx)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.logger.Errorf("scheduler: reload schedules: %v", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, row := range stored {
+		e, ok := s.entries[row.Name]
+		if !ok || (e.spec == row.Spec && e.enabled == row.Enabled) {
+			continue
+		}
+		if err := e.apply(row.Spec, row.Enabled, now); err != nil {
+			s.logger.Errorf("scheduler: schedule %s has an invalid spec %q, disabling it: %v", row.Name, row.Spec, err)
+			continue
+		}
+		s.logger.Info("scheduler: schedule reloaded", map[string]any{"schedule": row.Name, "spec": row.Spec, "enabled": row.Enabled})
+	}
+}
+
+// heartbeat keeps the runs of this replica alive and fails the runs of
+// replicas that stopped without finishing theirs
+func (s *Scheduler) heartbeat() {
+	ctx, cancel := context.WithTimeout(s.ctx, storageTimeout)
+	defer cancel()
+
+	if err := s.storage.HeartbeatScheduleRuns(ctx, s.owner); err != nil {
+		if s.ctx.Err() == nil {
+			s.logger.Errorf("scheduler: heartbeat runs: %v", err)
+		}
+		return
+	}
+	failed, err := s.storage.FailStaleScheduleRuns(ctx, int32(runStaleAfter/time.Second))
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.logger.Errorf("scheduler: close interrupted runs: %v", err)
+		}
+		return
+	}
+	if failed > 0 {
+		s.logger.Warnf("scheduler: closed %d runs of replicas that stopped", failed)
+	}
+}
+
+// dispatch claims and executes the run of e due at scheduledAt in the
+// background, so a slow database does not hold up the other schedules or the
+// API. It must be called with the scheduler mutex held.
+func (s *Scheduler) dispatch(e *entry, scheduledAt time.Time) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(e, scheduledAt)
+	}()
+}
+
+// run claims the run of e due at scheduledAt and executes it, unless another
+// replica claimed it first or the previous run is still going. A run that
+// cannot be claimed is not executed, as nothing would stop the other replicas
+// from executing it as well.
+func (s *Scheduler) run(e *entry, scheduledAt time.Time) {
+	name := e.name
+	ctx, cancel := context.WithTimeout(s.ctx, storageTimeout)
+	run, err := s.storage.ClaimScheduleRun(ctx, users_storage.ClaimScheduleRunParams{
+		ScheduleName: name,
+		ScheduledAt:  scheduledAt,
+		Owner:        s.owner,
+		StaleSeconds: int32(runStaleAfter / time.Second),
+	})
+	cancel()
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return // another replica claimed it
+	case err != nil:
+		if s.ctx.Err() == nil {
+			s.logger.Errorf("scheduler: %s: could not claim the run due at %s: %v", name, scheduledAt.Format(time.RFC3339), err)
+		}
+		return
+	case run.Status == RunSkipped:
+		s.logger.Warnf("scheduler: %s is still running, skipping this run", name)
+		return
+	}
+
+	s.mu.Lock()
+	e.running = true
+	job := e.job
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+
+	s.logger.Infof("scheduler: %s started", name)
+	started := time.Now()
+	jobErr := job(s.ctx)
+
+	status := RunSucceeded
+	switch {
+	case errors.Is(jobErr, errors_.ErrSyncInProgress), errors.Is(jobErr, errors_.ErrRetryInProgress):
+		status = RunSkipped
+	case jobErr != nil:
+		status = RunFailed
+	}
+	s.logger.Info("scheduler: run finished", map[string]any{
+		"schedule": name,
+		"status":   status,
+		"duration": time.Since(started).String(),
+		"error":    errorString(jobErr),
+	})
+
+	ctx, cancel = context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := s.storage.FinishScheduleRun(ctx, users_storage.FinishScheduleRunParams{
+		ID:     run.ID,
+		Status: status,
+		Error:  sql.NullString{String: errorString(jobErr), Valid: jobErr != nil},
+	}); err != nil {
+		s.logger.Errorf("scheduler: %s: failed to record run %d outcome: %v", name, run.ID, err)
+	}
+}
+
+// List returns every registered schedule with its next fire time and last run
+func (s *Scheduler) List(ctx context.Context) ([]dto.ScheduleResponse, error) {
+	latest, err := s.storage.ListLatestScheduleRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lastRuns := make(map[string]users_storage.ScheduleRun, len(latest))
+	for _, r := range latest {
+		lastRuns[r.ScheduleName] = r
+	}
+
+	s.mu.Lock()
+	schedules := make([]dto.ScheduleResponse, 0, len(s.entries))
+	for _, e := range s.entries {
+		item := e.response()
+		if r, ok := lastRuns[e.name]; ok {
+			item.LastRun = &r
+		}
+		schedules = append(schedules, item)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+// Update changes a schedule's spec and/or enabled flag, persists it and
+// reschedules it right away. Other replicas pick the change up on their next
+// reload, within reloadInterval. A run in progress is not interrupted.
+func (s *Scheduler) Update(ctx context.Context, name string, req *dto.UpdateScheduleRequest) (*dto.ScheduleResponse, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	if !ok {
+		s.mu.Unlock()
+		return nil, errors_.ErrScheduleNotFound
+	}
+	spec, enabled := e.spec, e.enabled
+	s.mu.Unlock()
+
+	if req.Spec != nil {
+		spec = *req.Spec
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	if enabled {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors_.ErrInvalidSchedule, err)
+		}
+	}
+
+	row, err := s.storage.UpdateSyncSchedule(ctx, users_storage.UpdateSyncScheduleParams{
+		Name:    name,
+		Spec:    spec,
+		Enabled: enabled,
+	})
+	if err != nil {
+		s.logger.Errorf("scheduler: update %s: %v", name, err)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	_ = e.apply(row.Spec, row.Enabled, time.Now())
+	resp := e.response()
+	s.mu.Unlock()
+
+	// let the loop pick up the new next fire time
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	s.logger.Info("scheduler: schedule updated", map[string]any{"schedule": name, "spec": row.Spec, "enabled": row.Enabled})
+	return &resp, nil
+}
+
+func (s *Scheduler) ListRuns(ctx context.Context, arg *users_storage.ListScheduleRunsParams) ([]users_storage.ScheduleRun, error) {
+	s.mu.Lock()
+	_, ok := s.entries[arg.ScheduleName]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors_.ErrScheduleNotFound
+	}
+
+	runs, err := s.storage.ListScheduleRuns(ctx, *arg)
+	if err != nil {
+		s.logger.Errorf("ListScheduleRuns: params=%+v err=%v", arg, err)
+		return nil, err
+	}
+	return runs, nil
+}
+
+// response must be called with the scheduler mutex held
+func (e *entry) response() dto.ScheduleResponse {
+	resp := dto.ScheduleResponse{
+		Name:    e.name,
+		Spec:    e.spec,
+		Enabled: e.enabled,
+		Running: e.running,
+	}
+	if e.enabled && !e.next.IsZero() {
+		next := e.next
+		resp.NextRun = &next
+	}
+	return resp
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// Account lifecycle states persisted in user_data.account_status. Only active
+// and missing users are ranked; a missing user still counts until its
+// disappearance is confirmed.
+const (
+	AccountActive  = "active"
+	AccountMissing = "missing"
+	AccountRenamed = "renamed"
+	AccountDeleted = "deleted"
+)
+
+// markUsersMissing counts a miss for every failure that says LeetCode no longer
+// knows the user, at most one per Accounts.MissInterval so a single bad day
+// cannot delete an account. Once the configured number of misses is reached the
+// account is deleted, and missing or deleted accounts that another stored
+// account took over (same custom avatar, or same real name, country and
+// avatar) are linked to it as renamed. The next successful fetch of the user
+// makes it active again.
+func (s *userService) markUsersMissing(ctx context.Context, failures []userFetchFailure) {
+	var usernames []string
+	for _, f := range failures {
+		if errors.Is(f.Err, errors_.ErrUserNotAvailable) {
+			usernames = append(usernames, f.Username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+
+	missed, err := s.storage.MarkUsersMissing(ctx, users_storage.MarkUsersMissingParams{
+		MissesToDelete:      int32(max(s.cfg.Accounts.MissesToDelete, 1)),
+		Usernames:           usernames,
+		MissIntervalSeconds: int32(s.cfg.Accounts.MissInterval.Seconds()),
+	})
+	if err != nil {
+		s.logger.Errorf("accounts: could not mark %d users missing: %v", len(usernames), err)
+		return
+	}
+	for _, m := range missed {
+		if m.AccountStatus == AccountDeleted {
+			s.logger.Infof("accounts: %s is deleted after %d misses", m.Username, m.MissCount)
+		}
+	}
+
+	renamed, err := s.storage.LinkRenamedUsers(ctx, usernames)
+	if err != nil {
+		s.logger.Errorf("accounts: could not link renamed users: %v", err)
+		return
+	}
+	for _, r := range renamed {
+		s.logger.Infof("accounts: %s was renamed to %s", r.Username, r.RenamedTo.String)
+	}
+}
+
+// ListAccounts pages through the users of one account status
+func (s *userService) ListAccounts(ctx context.Context, arg *users_storage.ListUsersByAccountStatusParams) (*dto.ListAccountsResponse, error) {
+	users, err := s.storage.ListUsersByAccountStatus(ctx, *arg)
+	if err != nil {
+		s.logger.Errorf("ListAccounts: params=%+v err=%v", arg, err)
+		return nil, err
+	}
+
+	totalCount, err := s.storage.CountUsersByAccountStatus(ctx, arg.AccountStatus)
+	if err != nil {
+		s.logger.Errorf("ListAccounts: count status=%s err=%v", arg.AccountStatus, err)
+		return nil, err
+	}
+	return &dto.ListAccountsResponse{
+		Users:      users,
+		TotalCount: totalCount,
+	}, nil
+}
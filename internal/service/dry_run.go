@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+// Kinds of change persisted in sync_job_changes.change
+const (
+	ChangeNewUser      = "new_user"
+	ChangeFieldChanged = "field_changed"
+	ChangeRankMoved    = "rank_moved"
+	ChangeDisappeared  = "disappeared"
+)
+
+// syncChange is one row of a dry-run report
+type syncChange struct {
+	username string
+	change   string
+	field    string
+	oldValue string
+	newValue string
+}
+
+// diffUsers lists what merging users into the stored rows would change, the
+// same way UpsertUserData (or UpsertRankingData in ranking mode) merges them:
+// contest fields only count when the record carries them, and a ranking record
+// carries no solved counts. Global rank moves are reported as rank_moved rather
+// than as a changed field.
+func diffUsers(mode SyncMode, stored map[string]users_storage.UserDatum, users []*models.StageUserDataParams) []syncChange {
+	var changes []syncChange
+	for _, r := range users {
+		u, ok := stored[r.Username]
+		if !ok {
+			changes = append(changes, syncChange{username: r.Username, change: ChangeNewUser, newValue: rankValue(r.GlobalRanking)})
+			continue
+		}
+
+		field := func(name, oldValue, newValue string) {
+			if strings.TrimSpace(oldValue) != strings.TrimSpace(newValue) {
+				changes = append(changes, syncChange{username: r.Username, change: ChangeFieldChanged, field: name, oldValue: oldValue, newValue: newValue})
+			}
+		}
+		count := func(name string, oldValue, newValue int32) {
+			if oldValue != newValue {
+				field(name, strconv.Itoa(int(oldValue)), strconv.Itoa(int(newValue)))
+			}
+		}
+
+		field("user_slug", u.UserSlug, r.UserSlug)
+		field("user_avatar", u.UserAvatar.String, r.UserAvatar)
+		field("country_code", u.CountryCode.String, r.CountryCode)
+		field("country_name", u.CountryName.String, r.CountryName)
+		field("real_name", u.RealName.String, r.RealName)
+		if mode != SyncModeRanking {
+			count("total_problems_solved", u.TotalProblemsSolved, r.TotalProblemsSolved)
+			count("total_submissions", u.TotalSubmissions, r.TotalSubmissions)
+			count("easy_solved", u.EasySolved, r.EasySolved)
+			count("medium_solved", u.MediumSolved, r.MediumSolved)
+			count("hard_solved", u.HardSolved, r.HardSolved)
+			count("easy_submissions", u.EasySubmissions, r.EasySubmissions)
+			count("medium_submissions", u.MediumSubmissions, r.MediumSubmissions)
+			count("hard_submissions", u.HardSubmissions, r.HardSubmissions)
+			count("all_submissions", u.AllSubmissions, r.AllSubmissions)
+		}
+		if r.ContestRating.Valid && (!u.ContestRating.Valid || math.Abs(u.ContestRating.Float64-r.ContestRating.Float64) >= 0.01) {
+			field("contest_rating", models.FormatRating(u.ContestRating), models.FormatRating(r.ContestRating))
+		}
+		if r.GlobalRanking.Valid && u.GlobalRanking != r.GlobalRanking {
+			changes = append(changes, syncChange{
+				username: r.Username,
+				change:   ChangeRankMoved,
+				field:    "global_ranking",
+				oldValue: rankValue(u.GlobalRanking),
+				newValue: rankValue(r.GlobalRanking),
+			})
+		}
+	}
+	return changes
+}
+
+func rankValue(v sql.NullInt32) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.Itoa(int(v.Int32))
+}
+
+// recordDryRunChanges stands in for the upsert of a dry-run job: it compares
+// users with what is stored and appends the differences to the job's report
+func (s *userService) recordDryRunChanges(ctx context.Context, jobID int64, mode SyncMode, users []*models.StageUserDataParams) error {
+	usernames := make([]string, 0, len(users))
+	for _, u := range users {
+		usernames = append(usernames, u.Username)
+	}
+	rows, err := s.storage.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return fmt.Errorf("load stored users: %w", err)
+	}
+	stored := make(map[string]users_storage.UserDatum, len(rows))
+	for _, row := range rows {
+		stored[row.Username] = row
+	}
+	return s.insertSyncChanges(ctx, jobID, diffUsers(mode, stored, users))
+}
+
+func (s *userService) insertSyncChanges(ctx context.Context, jobID int64, changes []syncChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	arg := users_storage.InsertSyncJobChangesParams{JobID: jobID}
+	for _, c := range changes {
+		arg.Usernames = append(arg.Usernames, c.username)
+		arg.Changes = append(arg.Changes, c.change)
+		arg.Fields = append(arg.Fields, c.field)
+		arg.OldValues = append(arg.OldValues, c.oldValue)
+		arg.NewValues = append(arg.NewValues, c.newValue)
+	}
+	if err := s.storage.InsertSyncJobChanges(ctx, arg); err != nil {
+		return fmt.Errorf("record %d changes: %w", len(changes), err)
+	}
+	return nil
+}
+
+// recordSeenUsers remembers the users a dry-run job found on ranking pages, so
+// the ones it never saw can be reported as disappeared at the end
+func (s *userService) recordSeenUsers(ctx context.Context, jobID int64, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	if err := s.storage.InsertSyncJobSeenUsers(ctx, users_storage.InsertSyncJobSeenUsersParams{
+		JobID:     jobID,
+		Usernames: usernames,
+	}); err != nil {
+		return fmt.Errorf("record %d seen users: %w", len(usernames), err)
+	}
+	return nil
+}
+
+// recordFetchFailures sends failures to the dead-letter queue. A dry run leaves
+// the queue alone and only reports the users LeetCode says no longer exist.
+func (s *userService) recordFetchFailures(jobID int64, page int, dryRun bool, failures []userFetchFailure) {
+	if !dryRun {
+		s.recordFailedFetches(jobID, page, failures)
+		return
+	}
+
+	var changes []syncChange
+	for _, f := range failures {
+		if errors.Is(f.Err, errors_.ErrUserNotAvailable) {
+			changes = append(changes, syncChange{username: f.Username, change: ChangeDisappeared})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+	if err := s.insertSyncChanges(ctx, jobID, changes); err != nil {
+		s.logger.Errorf("sync: job %d: %v", jobID, err)
+	}
+}
+
+// recordDisappearedUsers reports the ranked users a dry-run sweep of the whole
+// ranking did not see. A partial sweep cannot tell, so callers skip it then.
+func (s *userService) recordDisappearedUsers(jobID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), upsertTimeout)
+	defer cancel()
+
+	n, err := s.storage.InsertDisappearedUsers(ctx, jobID)
+	if err != nil {
+		s.logger.Errorf("sync: job %d: failed to record disappeared users: %v", jobID, err)
+		return
+	}
+	s.logger.Infof("sync: dry-run job %d: %d stored users are no longer ranked", jobID, n)
+}
+
+// GetSyncJobReport returns the changes a dry-run job found, with per-kind totals
+func (s *userService) GetSyncJobReport(ctx context.Context, jobID int64) (*dto.SyncJobReport, error) {
+	job, err := s.storage.GetSyncJob(ctx, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors_.ErrSyncJobNotFound
+	}
+	if err != nil {
+		s.logger.Errorf("GetSyncJobReport: job=%d err=%v", jobID, err)
+		return nil, err
+	}
+	if !job.DryRun {
+		return nil, errors_.ErrNotDryRun
+	}
+
+	changes, err := s.storage.ListSyncJobChanges(ctx, jobID)
+	if err != nil {
+		s.logger.Errorf("ListSyncJobChanges: job=%d err=%v", jobID, err)
+		return nil, err
+	}
+
+	report := &dto.SyncJobReport{Job: job, Changes: changes}
+	changed := make(map[string]struct{})
+	for _, c := range changes {
+		switch c.Change {
+		case ChangeNewUser:
+			report.Summary.NewUsers++
+		case ChangeFieldChanged:
+			report.Summary.FieldChanges++
+			changed[c.Username] = struct{}{}
+		case ChangeRankMoved:
+			report.Summary.RankMovements++
+		case ChangeDisappeared:
+			report.Summary.Disappeared++
+		}
+	}
+	report.Summary.ChangedUsers = len(changed)
+	return report, nil
+}
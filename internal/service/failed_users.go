@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+// Dead-letter statuses persisted in failed_user_fetches.status
+const (
+	FailedUserPending   = "pending"
+	FailedUserPermanent = "permanent"
+)
+
+const failedUsersWriteTimeout = 10 * time.Second
+
+// userFetchFailure is a username whose profile could not be fetched, with the final error
+type userFetchFailure struct {
+	Username string
+	Err      error
+}
+
+// recordFailedFetches adds failures to the dead-letter table and returns how many
+// of them are now permanently failed. jobID and page are 0 outside a page sweep.
+// Users LeetCode reports as not existing are permanent at once, as retrying
+// them blindly is pointless, and get a miss on their account instead; the retry
+// pass re-checks them until the account is confirmed deleted.
+func (s *userService) recordFailedFetches(jobID int64, page int, failures []userFetchFailure) int {
+	if len(failures) == 0 {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), failedUsersWriteTimeout)
+	defer cancel()
+
+	s.markUsersMissing(ctx, failures)
+
+	permanent := 0
+	for _, f := range failures {
+		maxAttempts := s.cfg.FailedUsers.MaxAttempts
+		if errors.Is(f.Err, errors_.ErrUserNotAvailable) {
+			maxAttempts = 1
+		}
+		row, err := s.storage.RecordFailedUserFetch(ctx, users_storage.RecordFailedUserFetchParams{
+			Username:    f.Username,
+			Page:        sql.NullInt32{Int32: int32(page), Valid: page > 0},
+			JobID:       sql.NullInt64{Int64: jobID, Valid: jobID > 0},
+			ErrorClass:  string(errors_.ClassOf(f.Err)),
+			LastError:   sql.NullString{String: f.Err.Error(), Valid: true},
+			MaxAttempts: int32(maxAttempts),
+		})
+		if err != nil {
+			s.logger.Errorf("failed users: could not record %q: %v", f.Username, err)
+			continue
+		}
+		if row.Status == FailedUserPermanent {
+			permanent++
+		}
+	}
+	return permanent
+}
+
+// clearFailedFetches drops dead-letter entries of users that were just stored
+func (s *userService) clearFailedFetches(users []*models.StageUserDataParams) {
+	if len(users) == 0 {
+		return
+	}
+	usernames := make([]string, 0, len(users))
+	for _, u := range users {
+		usernames = append(usernames, u.Username)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failedUsersWriteTimeout)
+	defer cancel()
+	if err := s.storage.DeleteFailedUserFetches(ctx, usernames); err != nil {
+		s.logger.Errorf("failed users: could not clear %d resolved users: %v", len(usernames), err)
+	}
+}
+
+// RetryFailedUsers re-fetches up to limit pending dead-letter users and stores the
+// ones that succeed. Only one pass runs at a time.
+func (s *userService) RetryFailedUsers(ctx context.Context, limit int) (*dto.RetryFailedUsersResponse, error) {
+	if !s.retryMu.TryLock() {
+		return nil, errors_.ErrRetryInProgress
+	}
+	defer s.retryMu.Unlock()
+
+	return s.retryFailedUsers(ctx, limit)
+}
+
+// StartFailedUsersRetry runs a retry pass in the background under the service
+// lifecycle, so it outlives the admin request that triggered it.
+func (s *userService) StartFailedUsersRetry(limit int) error {
+	if !s.retryMu.TryLock() {
+		return errors_.ErrRetryInProgress
+	}
+
+	s.syncWG.Add(1)
+	go func() {
+		defer s.syncWG.Done()
+		defer s.retryMu.Unlock()
+		if _, err := s.retryFailedUsers(s.syncCtx, limit); err != nil {
+			s.logger.Errorf("failed users: retry pass failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *userService) retryFailedUsers(ctx context.Context, limit int) (*dto.RetryFailedUsersResponse, error) {
+	if limit <= 0 {
+		limit = s.cfg.FailedUsers.RetryBatchSize
+	}
+	pending, err := s.storage.ListFailedUserFetches(ctx, users_storage.ListFailedUserFetchesParams{
+		Status: FailedUserPending,
+		Limit:  int32(limit),
+		Offset: 0,
+	})
+	if err != nil {
+		s.logger.Errorf("RetryFailedUsers: list pending: %v", err)
+		return nil, err
+	}
+
+	usernames := make([]string, 0, len(pending))
+	for _, p := range pending {
+		usernames = append(usernames, p.Username)
+	}
+
+	// Missing accounts are re-checked on top of the pending users, so a full
+	// queue never holds back confirming a deletion
+	recheck, err := s.storage.ListMissingUsersToRecheck(ctx, users_storage.ListMissingUsersToRecheckParams{
+		MissIntervalSeconds: int32(s.cfg.Accounts.MissInterval.Seconds()),
+		LimitCount:          int32(limit),
+	})
+	if err != nil {
+		s.logger.Errorf("RetryFailedUsers: list missing users: %v", err)
+		return nil, err
+	}
+	usernames = append(usernames, recheck...)
+
+	resp := &dto.RetryFailedUsersResponse{Attempted: len(usernames)}
+	if len(usernames) == 0 {
+		return resp, nil
+	}
+
+	users, failures, err := s.refreshUsers(ctx, usernames, s.cfg.FailedUsers.Workers)
+	if err != nil {
+		s.logger.Errorf("RetryFailedUsers: %v", err)
+		return nil, fmt.Errorf("retry failed users: %w", err)
+	}
+
+	resp.Recovered = len(users)
+	resp.Permanent = s.recordFailedFetches(0, 0, failures)
+	resp.StillPending = len(failures) - resp.Permanent
+
+	s.logger.Info("failed users: retry pass finished", map[string]any{
+		"attempted":     resp.Attempted,
+		"recovered":     resp.Recovered,
+		"still_pending": resp.StillPending,
+		"permanent":     resp.Permanent,
+	})
+	return resp, nil
+}
+
+func (s *userService) ListFailedUsers(ctx context.Context, arg *users_storage.ListFailedUserFetchesParams) (*dto.ListFailedUsersResponse, error) {
+	users, err := s.storage.ListFailedUserFetches(ctx, *arg)
+	if err != nil {
+		s.logger.Errorf("ListFailedUsers: params=%+v err=%v", arg, err)
+		return nil, err
+	}
+
+	totalCount, err := s.storage.CountFailedUserFetches(ctx, arg.Status)
+	if err != nil {
+		s.logger.Errorf("ListFailedUsers: count status=%s err=%v", arg.Status, err)
+		return nil, err
+	}
+	return &dto.ListFailedUsersResponse{
+		Users:      users,
+		TotalCount: totalCount,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyncMode selects which users on a ranking page get a queryMatchedUser call
+type SyncMode string
+
+const (
+	SyncModeFull        SyncMode = "full"        // every user on the page
+	SyncModeIncremental SyncMode = "incremental" // only users that are stale, see staleUsernames
+	SyncModeRanking     SyncMode = "ranking"     // nobody: rating, rank and profile come from the ranking page itself
+)
+
+const defaultIncrementalMaxAge = 7 * 24 * time.Hour
+
+// staleUsernames returns the usernames on a ranking page that need refreshing:
+// users we have never stored, users whose stats were not fetched within maxAge
+// (including users only ever written by a ranking sync), and users whose
+// contest rating on the ranking page differs from the stored one. Solved counts
+// are not on the ranking page, so maxAge bounds how stale they can get.
+func (s *userService) staleUsernames(ctx context.Context, usernames []string, nodes map[string]RankingNode, maxAge time.Duration) ([]string, error) {
+	rows, err := s.storage.GetUsersFreshness(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]int, len(rows))
+	for i, row := range rows {
+		stored[row.Username] = i
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	stale := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		i, ok := stored[username]
+		if !ok || !rows[i].StatsFetchedAt.Valid || rows[i].StatsFetchedAt.Time.Before(cutoff) {
+			stale = append(stale, username)
+			continue
+		}
+
+		node, ok := nodes[username]
+		if !ok {
+			continue
+		}
+		rating, err := strconv.ParseFloat(strings.TrimSpace(node.CurrentRating), 64)
+		if err != nil {
+			continue
+		}
+		if !rows[i].ContestRating.Valid || math.Abs(rows[i].ContestRating.Float64-rating) >= 0.01 {
+			stale = append(stale, username)
+		}
+	}
+	return stale, nil
+}
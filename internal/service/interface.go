@@ -13,10 +13,30 @@ type UserService interface {
 	DeleteUserByUsername(ctx context.Context, username string) error
 	GetUserByUsername(ctx context.Context, username string) (*users_storage.UserDatum, error)
 	GetUserData(ctx context.Context, username string) (*models.StageUserDataParams, error)
+	GetUserHistory(ctx context.Context, req *dto.GetUserHistoryRequest) (*dto.GetUserHistoryResponse, error)
 	GetUsersByCountry(ctx context.Context, arg *users_storage.GetUsersByCountryParams) (*dto.GetUsersByCountryResponse, error)
 	SyncLeaderboard(ctx context.Context, opts SyncOptions) error
 	UpdateUserByUsername(ctx context.Context, arg *users_storage.UpdateUserByUsernameParams) (*users_storage.UserDatum, error)
-	SyncOff()
-	SyncOn()
-	GetSyncStatus() *dto.GetSyncStatusResponse
+	GetSyncStatus(ctx context.Context) (*dto.GetSyncStatusResponse, error)
+	ListSyncJobs(ctx context.Context, arg *users_storage.ListSyncJobsParams) ([]users_storage.SyncJob, error)
+	ListSyncJobLeases(ctx context.Context, jobID int64) ([]users_storage.SyncPageLease, error)
+	GetSyncJobReport(ctx context.Context, jobID int64) (*dto.SyncJobReport, error)
+	ResumeInterruptedSync(ctx context.Context) error
+	StartLeaseWorker()
+	StartSync(opts SyncOptions) error
+	PauseSync() error
+	ResumeSync() error
+	CancelSync() error
+	Shutdown(ctx context.Context) error
+	ListFailedUsers(ctx context.Context, arg *users_storage.ListFailedUserFetchesParams) (*dto.ListFailedUsersResponse, error)
+	RetryFailedUsers(ctx context.Context, limit int) (*dto.RetryFailedUsersResponse, error)
+	StartFailedUsersRetry(limit int) error
+	ListAccounts(ctx context.Context, arg *users_storage.ListUsersByAccountStatusParams) (*dto.ListAccountsResponse, error)
+	RefreshOverdueUsers(ctx context.Context) error
+	CreateTrackedGroup(ctx context.Context, name string) (*users_storage.TrackedGroup, error)
+	DeleteTrackedGroup(ctx context.Context, name string) error
+	ListTrackedGroups(ctx context.Context) ([]users_storage.ListTrackedGroupsRow, error)
+	ListTrackedGroupMembers(ctx context.Context, name string) ([]string, error)
+	AddTrackedGroupMembers(ctx context.Context, name string, usernames []string) error
+	RemoveTrackedGroupMember(ctx context.Context, name, username string) error
 }
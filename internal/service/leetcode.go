@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,16 +22,19 @@ import (
 	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
 	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
 	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/cassette"
 	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
 )
 
-const leetcodeURL = "https://leetcode.com/graphql"
-
 type LeetCodeClient struct {
 	httpClient *http.Client
+	url        string
 	debug      bool
-	delay      time.Duration
 	headers    http.Header
+	limiter    *adaptiveLimiter
+
+	// usernames per aliased matchedUser query, 1 sends one query per user
+	userBatchSize int
 }
 
 var queryGlobalRanking = `query globalRanking($page: Int) {
@@ -94,7 +100,7 @@ var queryMatchedUser = `query userProfilePublicProfile($username: String!) {
 type GraphQLError struct {
 	Message    string                 `json:"message"`
 	Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
-	Path       []string               `json:"path,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"` // field names and list indexes
 	Extensions map[string]interface{} `json:"extensions,omitempty"`
 }
 
@@ -198,11 +204,28 @@ func NewLeetCodeClient(cfg *config.Config) *LeetCodeClient {
 	h.Set("Sec-Fetch-Mode", "cors")
 	h.Set("Sec-Fetch-Site", "same-origin")
 
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if cfg.CassetteMode != "" {
+		transport, err := cassette.New(cassette.Mode(cfg.CassetteMode), cfg.CassetteDir, nil)
+		if err != nil {
+			log.Fatalf("leetcode client: %v", err)
+		}
+		httpClient.Transport = transport
+		log.Printf("leetcode client: %s mode, cassettes in %s", cfg.CassetteMode, cfg.CassetteDir)
+	}
+
 	return &LeetCodeClient{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpClient,
+		url:        cfg.URL,
 		debug:      cfg.Debug,
-		delay:      cfg.Delay,
 		headers:    h,
+		limiter: newAdaptiveLimiter(
+			cfg.RequestsPerSecond,
+			cfg.Burst,
+			cfg.MinRequestsPerSecond,
+			cfg.RecoverAfter,
+		),
+		userBatchSize: cfg.UserBatchSize,
 	}
 }
 
@@ -211,44 +234,91 @@ type SyncOptions struct {
 	StartPage int           // 1-based
 	Pages     int           // <=0 to fetch all pages
 	Workers   int           // goroutines for per-user fetch+upsert
-	Delay     time.Duration // polite delay between page requests
-	BatchSize int           // users to process in each batch
+	Delay     time.Duration // optional pause between ranking pages; request pacing is done by the client's rate limiter
+	BatchSize int           // users upserted per write, across pages
+	Lookahead int           // ranking pages fetched ahead of the user stage
+	JobID     int64         // resume this sync job from its checkpoint instead of starting a new one
+	Mode      SyncMode      // full (default), incremental or ranking
+	MaxAge    time.Duration // incremental: users refreshed longer ago than this are always re-fetched
+	// Distributed splits the pages into leases that every replica works on, see sync_page_leases
+	Distributed bool
+	// Target refreshes a set of users instead of sweeping pages; StartPage, Pages,
+	// Lookahead and Mode do not apply to it
+	Target SyncTarget
+	// DryRun fetches as usual but writes nothing to user_data; the changes it would
+	// have made are reported in sync_job_changes, see GetSyncJobReport
+	DryRun bool
 }
 
 // OPTIMIZED: Single method that handles both fetching and converting user data
-func (s *userService) fetchAndConvertUser(username string) (*models.StageUserDataParams, error) {
+func (s *userService) fetchAndConvertUser(ctx context.Context, policy RetryPolicy, username string) (*models.StageUserDataParams, error) {
 	username = strings.TrimSpace(username)
 	if username == "" {
 		return nil, fmt.Errorf("username is required")
 	}
 
+	matched, err := s.source.FetchMatchedUser(ctx, policy, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.convertMatchedUser(username, matched)
+}
+
+// FetchMatchedUser fetches the profile and solved stats of one user
+func (c *LeetCodeClient) FetchMatchedUser(ctx context.Context, policy RetryPolicy, username string) (*MatchedUser, error) {
 	var out ResponseUser
-	if err := s.leetCodeClient.doGraphQL(queryMatchedUser, map[string]interface{}{"username": username}, &out); err != nil {
+	if err := c.doGraphQL(ctx, policy, queryMatchedUser, map[string]interface{}{"username": username}, &out); err != nil {
 		return nil, fmt.Errorf("leetcode fetch failed for %q: %w", username, err)
 	}
+	return matchedUserOrError(username, out.Data.MatchedUser, out.Errors)
+}
 
+// FetchRankingPage fetches one page of the global contest ranking
+func (c *LeetCodeClient) FetchRankingPage(ctx context.Context, policy RetryPolicy, page int) (*ResponseGlobal, error) {
+	var out ResponseGlobal
+	if err := c.doGraphQL(ctx, policy, queryGlobalRanking, map[string]interface{}{"page": page}, &out); err != nil {
+		return nil, err
+	}
 	if len(out.Errors) > 0 {
-		return nil, fmt.Errorf("GraphQL errors for user %q: %+v", username, out.Errors)
+		return nil, errors_.NewLeetCodeError(errors_.ClassGraphQL, 0, fmt.Errorf("page %d: %+v", page, out.Errors))
 	}
+	return &out, nil
+}
+
+// UserBatchSize is the number of users aliased into one matchedUser query
+func (c *LeetCodeClient) UserBatchSize() int {
+	return c.userBatchSize
+}
+
+// Rate is the request rate the client currently allows itself, in requests per second
+func (c *LeetCodeClient) Rate() float64 {
+	return c.limiter.Rate()
+}
 
-	if out.Data.MatchedUser == nil {
+// matchedUserOrError turns a matchedUser field and the GraphQL errors reported
+// for it into a user or a classified error
+func matchedUserOrError(username string, matched *MatchedUser, errs []GraphQLError) (*MatchedUser, error) {
+	// LeetCode reports a missing user as matchedUser: null plus a "does not exist" error
+	if matched == nil && userMissing(errs) {
 		return nil, errors_.ErrUserNotAvailable
 	}
 
-	// Find AC stats for "All" difficulty
-	var acAll *ACStat
-	for i := range out.Data.MatchedUser.SubmitStats.ACSubmissionNum {
-		if out.Data.MatchedUser.SubmitStats.ACSubmissionNum[i].Difficulty == "All" {
-			acAll = &out.Data.MatchedUser.SubmitStats.ACSubmissionNum[i]
-			break
-		}
+	if len(errs) > 0 || matched == nil {
+		return nil, errors_.NewLeetCodeError(errors_.ClassGraphQL, 0, fmt.Errorf("user %q: %+v", username, errs))
 	}
+	return matched, nil
+}
 
+func (s *userService) convertMatchedUser(username string, matched *MatchedUser) (*models.StageUserDataParams, error) {
+	stats := matched.SubmitStats
+
+	// Find AC stats for "All" difficulty
+	acAll := findStat(stats.ACSubmissionNum, "All")
 	if acAll == nil {
-		return nil, fmt.Errorf("missing AC 'All' statistics for user %q", username)
+		return nil, errors_.NewLeetCodeError(errors_.ClassSchemaChange, 0, fmt.Errorf("missing AC 'All' statistics for user %q", username))
 	}
 
-	profile := out.Data.MatchedUser.Profile
+	profile := matched.Profile
 
 	// Optional logging
 	s.logger.Infof("Fetched user=%s solved=%d submissions=%d country=%s",
@@ -264,18 +334,69 @@ func (s *userService) fetchAndConvertUser(username string) (*models.StageUserDat
 		Typename:            profile.Typename,
 		TotalProblemsSolved: int32(acAll.Count),
 		TotalSubmissions:    int32(acAll.Submissions),
+		EasySolved:          statCount(stats.ACSubmissionNum, "Easy"),
+		MediumSolved:        statCount(stats.ACSubmissionNum, "Medium"),
+		HardSolved:          statCount(stats.ACSubmissionNum, "Hard"),
+		EasySubmissions:     statSubmissions(stats.TotalSubmissionNum, "Easy"),
+		MediumSubmissions:   statSubmissions(stats.TotalSubmissionNum, "Medium"),
+		HardSubmissions:     statSubmissions(stats.TotalSubmissionNum, "Hard"),
+		AllSubmissions:      statSubmissions(stats.TotalSubmissionNum, "All"),
 	}, nil
 }
 
+// userMissing reports whether LeetCode said the user does not exist. A null
+// matchedUser without such an error is not proof of a deleted account, and is
+// handled as a GraphQL error instead, so it never counts as a miss.
+func userMissing(errs []GraphQLError) bool {
+	for _, e := range errs {
+		if strings.Contains(strings.ToLower(e.Message), "does not exist") {
+			return true
+		}
+	}
+	return false
+}
+
+// findStat returns the entry for the given difficulty ("All", "Easy", "Medium", "Hard")
+func findStat(stats []ACStat, difficulty string) *ACStat {
+	for i := range stats {
+		if stats[i].Difficulty == difficulty {
+			return &stats[i]
+		}
+	}
+	return nil
+}
+
+func statCount(stats []ACStat, difficulty string) int32 {
+	if st := findStat(stats, difficulty); st != nil {
+		return int32(st.Count)
+	}
+	return 0
+}
+
+func statSubmissions(stats []ACStat, difficulty string) int32 {
+	if st := findStat(stats, difficulty); st != nil {
+		return int32(st.Submissions)
+	}
+	return 0
+}
+
+// pauseGate blocks while the caller's work is paused and returns an error once
+// it should stop, like syncController.waitIfPaused
+type pauseGate func(ctx context.Context) error
+
 // OPTIMIZED: Concurrent user processing with worker pools
-func (s *userService) processUsersConcurrently(ctx context.Context, usernames []string, workers int, delay time.Duration) ([]*models.StageUserDataParams, error) {
+// Workers pass through pause before every batch; sync jobs hand in their
+// controller's gate, other callers nil so a paused sweep does not hold them up.
+func (s *userService) processUsersConcurrently(ctx context.Context, usernames []string, workers int, pause pauseGate) ([]*models.StageUserDataParams, []userFetchFailure, error) {
 	if workers <= 0 {
 		workers = 1
 	}
 
-	jobs := make(chan string, len(usernames))
+	// Each job is one batch query for up to the client's user batch size
+	batches := s.userBatches(usernames)
+	jobs := make(chan []string, len(batches))
 	results := make(chan *models.StageUserDataParams, len(usernames))
-	errors := make(chan error, len(usernames))
+	errors := make(chan userFetchFailure, len(usernames))
 
 	// Start workers
 	var wg sync.WaitGroup
@@ -283,25 +404,22 @@ func (s *userService) processUsersConcurrently(ctx context.Context, usernames []
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for username := range jobs {
-				// select {
-				// case <-ctx.Done():
-				// 	errors <- ctx.Err()
-				// 	return
-				// default:
-				// }
-
-				user, err := s.fetchAndConvertUser(username)
-				if err != nil {
-					s.logger.Error("failed to fetch user", map[string]any{"username": username, "error": err})
-					errors <- err
-				} else {
-					results <- user
+			for batch := range jobs {
+				if pause != nil {
+					if err := pause(ctx); err != nil {
+						return
+					}
 				}
 
-				// Polite delay between requests
-				if delay > 0 {
-					time.Sleep(delay)
+				users, failures := s.fetchAndConvertUsers(ctx, s.retry.user, batch)
+				if ctx.Err() != nil {
+					return
+				}
+				for _, user := range users {
+					results <- user
+				}
+				for _, failure := range failures {
+					errors <- failure
 				}
 			}
 		}()
@@ -310,11 +428,11 @@ func (s *userService) processUsersConcurrently(ctx context.Context, usernames []
 	// Send jobs
 	go func() {
 		defer close(jobs)
-		for _, username := range usernames {
+		for _, batch := range batches {
 			select {
-			// case <-ctx.Done():
-			// 	return
-			case jobs <- username:
+			case <-ctx.Done():
+				return
+			case jobs <- batch:
 			}
 		}
 	}()
@@ -328,7 +446,7 @@ func (s *userService) processUsersConcurrently(ctx context.Context, usernames []
 
 	// Collect results
 	var users []*models.StageUserDataParams
-	var errs []error
+	var errs []userFetchFailure
 
 	for {
 		select {
@@ -338,14 +456,12 @@ func (s *userService) processUsersConcurrently(ctx context.Context, usernames []
 			} else {
 				users = append(users, user)
 			}
-		case err, ok := <-errors:
+		case failure, ok := <-errors:
 			if !ok {
 				errors = nil
 			} else {
-				errs = append(errs, err)
+				errs = append(errs, failure)
 			}
-			// case <-ctx.Done():
-			// 	return nil, ctx.Err()
 		}
 
 		if results == nil && errors == nil {
@@ -353,23 +469,51 @@ func (s *userService) processUsersConcurrently(ctx context.Context, usernames []
 		}
 	}
 
+	// Results of a cancelled batch are incomplete; let the caller decide what to keep
+	if err := ctx.Err(); err != nil {
+		return users, errs, err
+	}
+
 	if len(errs) > 0 {
 		s.logger.Warnf("encountered %d errors while processing %d users", len(errs), len(usernames))
 	}
 
-	return users, nil
+	return users, errs, nil
 }
 
-// OPTIMIZED: Main sync method with improved batching and concurrency
+// SyncLeaderboard runs a sync job in the caller's goroutine. It fails with
+// errors_.ErrSyncInProgress when another job owns the sync controller.
 func (s *userService) SyncLeaderboard(ctx context.Context, opts SyncOptions) error {
+	// The job runs under the service lifecycle so Shutdown interrupts it the same
+	// way as a background sync, leaving it resumable. The caller giving up on ctx
+	// cancels it like /sync/cancel does.
+	if err := validateSyncOptions(&opts); err != nil {
+		return err
+	}
+	jobCtx, err := s.controller.begin(s.syncCtx)
+	if err != nil {
+		return err
+	}
+	s.syncWG.Add(1)
+	defer s.syncWG.Done()
+
+	stop := context.AfterFunc(ctx, func() { _ = s.controller.cancelJob() })
+	err = s.runSync(jobCtx, opts)
+	stop()
+	s.controller.finish(err)
+	return err
+}
+
+// OPTIMIZED: Main sync method with improved batching and concurrency
+func (s *userService) runSync(ctx context.Context, opts SyncOptions) error {
 	pp.Println("------------------ starting synchronization -----------------")
 
 	// Set defaults
 	if opts.StartPage < 1 {
 		opts.StartPage = 1
 	}
-	if opts.Delay <= 0 {
-		opts.Delay = 800 * time.Millisecond
+	if opts.Delay < 0 {
+		opts.Delay = 0
 	}
 	if opts.Workers <= 0 {
 		opts.Workers = 3 // Slightly more aggressive default
@@ -377,15 +521,55 @@ func (s *userService) SyncLeaderboard(ctx context.Context, opts SyncOptions) err
 	if opts.BatchSize <= 0 {
 		opts.BatchSize = 100 // Process users in batches
 	}
+	if opts.Lookahead <= 0 {
+		opts.Lookahead = defaultPageLookahead
+	}
+	if opts.Mode == "" {
+		opts.Mode = SyncModeFull
+	}
+	if opts.Mode == SyncModeIncremental && opts.MaxAge <= 0 {
+		opts.MaxAge = defaultIncrementalMaxAge
+	}
 
-	pp.Printf("sync: starting page-by-page sync from page %d, delay=%s, workers=%d, batch_size=%d\n",
-		opts.StartPage, opts.Delay, opts.Workers, opts.BatchSize)
+	// Persist the job (or pick up the one we are resuming) before touching LeetCode
+	job, err := s.startSyncJob(&opts)
+	if err != nil {
+		s.logger.Errorf("sync: %v", err)
+		return err
+	}
+	s.controller.setJob(job.ID)
+
+	// keep the job claimed while it runs; losing it to another replica stops this run
+	ctx, cancelJob := context.WithCancelCause(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		s.heartbeatSyncJob(ctx, cancelJob, job.ID)
+	}()
+	defer func() { <-heartbeatDone }()
+	defer cancelJob(nil)
+
+	if job.Target != SyncTargetPages {
+		return s.runTargetedSync(ctx, job, opts)
+	}
+
+	if job.EndPage > 0 && opts.StartPage > int(job.EndPage) {
+		s.finishSyncJob(job.ID, SyncJobFinished)
+		s.logger.Infof("sync: job %d has no pages left", job.ID)
+		return nil
+	}
+
+	pp.Printf("sync: job %d starting %s page-by-page sync from page %d, delay=%s, workers=%d, batch_size=%d, rps=%.2f\n",
+		job.ID, opts.Mode, opts.StartPage, opts.Delay, opts.Workers, opts.BatchSize, s.sourceRate())
 
 	// Get first page to determine total pages
-	firstPage, err := s.fetchRankingPage(opts.StartPage)
+	firstPage, err := s.fetchRankingPage(ctx, opts.StartPage)
 	if err != nil {
-		s.logger.Errorf("sync: failed to fetch first page %d: %v", opts.StartPage, err)
-		return fmt.Errorf("fetch first page: %w", err)
+		if ctx.Err() != nil {
+			s.logger.Infof("sync: job %d interrupted before the first page, it stays resumable", job.ID)
+			return ctx.Err()
+		}
+		return s.failSyncJob(job.ID, opts.StartPage, fmt.Errorf("fetch first page %d: %w", opts.StartPage, err))
 	}
 
 	totalPages := firstPage.Data.GlobalRanking.TotalPages
@@ -398,89 +582,164 @@ func (s *userService) SyncLeaderboard(ctx context.Context, opts SyncOptions) err
 			endPage = calculatedEnd
 		}
 	}
+	s.setSyncJobRange(job.ID, endPage, totalPages)
 
-	pp.Printf("sync: will process pages %d to %d\n", opts.StartPage, endPage)
+	// Only a sweep of the whole ranking can tell that a stored user is gone
+	wholeRanking := opts.DryRun && job.StartPage <= 1 && endPage == totalPages
 
-	totalProcessedUsers := 0
-
-	// Process pages in batches
-	for currentPage := opts.StartPage; s.sync && currentPage <= endPage; currentPage++ {
-		s.syncingPage = currentPage
-		// select {
-		// case <-ctx.Done():
-		// 	s.logger.Errorf("sync: context canceled at page %d", currentPage)
-		// 	return ctx.Err()
-		// default:
-		// }
-
-		pp.Printf("sync: processing page %d/%d\n", currentPage, endPage)
-
-		// Fetch current page (reuse first page data if it's the start page)
-		var pageResp *ResponseGlobal
-		if currentPage == opts.StartPage && firstPage != nil {
-			pageResp = firstPage
-		} else {
-			pageResp, err = s.fetchRankingPage(currentPage)
-			if err != nil {
-				s.logger.Errorf("sync: failed to fetch page %d: %v", currentPage, err)
-				continue // Skip this page and continue with next
-			}
+	if job.Distributed {
+		err := s.coordinateLeasedSync(ctx, job, opts.StartPage, endPage)
+		if err == nil && wholeRanking {
+			s.recordDisappearedUsers(job.ID)
+		}
+		if err == nil && !opts.DryRun {
+			s.recomputeRefreshTiers(ctx)
 		}
+		return err
+	}
 
-		// Extract usernames from current page
-		usernames := s.extractUsernamesFromPage(pageResp)
-		pp.Printf("sync: page %d contains %d users\n", currentPage, len(usernames))
+	pp.Printf("sync: will process pages %d to %d\n", opts.StartPage, endPage)
 
-		// Process users concurrently
-		users, err := s.processUsersConcurrently(ctx, usernames, opts.Workers, opts.Delay)
+	totalProcessedUsers, failedPage, err := s.runPipeline(ctx, job.ID, opts, firstPage, endPage)
+	if ctx.Err() != nil {
+		return s.interruptSyncJob(ctx, job.ID, totalProcessedUsers)
+	}
+	if err != nil {
+		// Retries are exhausted or the error is permanent. Skipping the page would
+		// silently lose its users, so fail the job at its last checkpoint instead;
+		// resuming it by ID starts again from this page.
+		return s.failSyncJob(job.ID, failedPage, err)
+	}
+
+	if wholeRanking {
+		s.recordDisappearedUsers(job.ID)
+	}
+	s.finishSyncJob(job.ID, SyncJobFinished)
+	if !opts.DryRun {
+		s.recomputeRefreshTiers(ctx)
+	}
+	s.logger.Infof("sync: completed all pages. Total processed users: %d", totalProcessedUsers)
+	pp.Println("------------------ synchronization completed -----------------")
+	return nil
+}
+
+// pageResult counts what happened to the users of one ranking page
+type pageResult struct {
+	processed int
+	failed    int
+	skipped   int
+}
+
+// syncPage refreshes and upserts the users of one ranking page, fetching the page
+// first when pageResp is nil. Callers check ctx.Err() before treating a returned
+// error as a failure of the page.
+func (s *userService) syncPage(ctx context.Context, jobID int64, page int, pageResp *ResponseGlobal, opts SyncOptions) (pageResult, error) {
+	var res pageResult
+	if pageResp == nil {
+		var err error
+		pageResp, err = s.fetchRankingPage(ctx, page)
 		if err != nil {
-			s.logger.Errorf("sync: failed to process users on page %d: %v", currentPage, err)
-			continue
+			return res, fmt.Errorf("fetch page %d: %w", page, err)
+		}
+	}
+
+	// Extract usernames from current page
+	usernames := s.extractUsernamesFromPage(pageResp)
+	pp.Printf("sync: page %d contains %d users\n", page, len(usernames))
+	nodes := rankingNodesByUsername(pageResp)
+	if opts.DryRun {
+		if err := s.recordSeenUsers(ctx, jobID, usernames); err != nil {
+			return res, fmt.Errorf("page %d: %w", page, err)
 		}
+	}
 
-		// Batch insert users
-		if len(users) > 0 {
-			c, cancel := context.WithTimeout(context.TODO(), time.Second*20)
-			err := s.dbStorage.UpsertUserData(c, users)
+	var users []*models.StageUserDataParams
+	if opts.Mode == SyncModeRanking {
+		users = rankingPageUsers(usernames, nodes)
+	} else {
+		if opts.Mode == SyncModeIncremental {
+			stale, err := s.staleUsernames(ctx, usernames, nodes, opts.MaxAge)
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
 			if err != nil {
-				s.logger.Error("failed to sync users", map[string]any{"page": currentPage, "count": len(users)})
-				pp.Println(err.Error())
+				s.logger.Warnf("sync: page %d: could not check freshness, refreshing every user: %v", page, err)
 			} else {
-				totalProcessedUsers += len(users)
-				s.logger.Infof("sync: completed page %d/%d - processed %d users (total: %d)",
-					currentPage, endPage, len(users), totalProcessedUsers)
+				res.skipped = len(usernames) - len(stale)
+				usernames = stale
 			}
-			cancel()
 		}
 
-		// Optional: delay between pages
-		if currentPage < endPage {
-			time.Sleep(opts.Delay)
+		// Process users concurrently
+		fetched, failures, err := s.processUsersConcurrently(ctx, usernames, opts.Workers, s.controller.waitIfPaused)
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if err != nil {
+			return res, fmt.Errorf("process users on page %d: %w", page, err)
+		}
+		users = fetched
+		res.failed = len(usernames) - len(users)
+		s.recordFetchFailures(jobID, page, opts.DryRun, failures)
+	}
+
+	// Attach contest rating and rank from the ranking page
+	for _, user := range users {
+		if node, ok := nodes[user.Username]; ok {
+			applyRankingNode(user, node)
 		}
 	}
 
-	s.logger.Infof("sync: completed all pages. Total processed users: %d", totalProcessedUsers)
-	pp.Println("------------------ synchronization completed -----------------")
-	return nil
+	// Batch insert users
+	if len(users) > 0 {
+		c, cancel := context.WithTimeout(ctx, upsertTimeout)
+		err := s.upsertSyncedUsers(c, jobID, opts, users)
+		cancel()
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if err != nil {
+			s.logger.Error("failed to sync users", map[string]any{"page": page, "count": len(users)})
+			return res, fmt.Errorf("upsert page %d: %w", page, err)
+		}
+		res.processed = len(users)
+	}
+	return res, nil
 }
 
-func (s *userService) GetSyncStatus() *dto.GetSyncStatusResponse {
-	return &dto.GetSyncStatusResponse{
-		IsOn: s.sync,
-		Page: s.syncingPage,
+func (s *userService) GetSyncStatus(ctx context.Context) (*dto.GetSyncStatusResponse, error) {
+	snap := s.controller.snapshot()
+	resp := &dto.GetSyncStatusResponse{
+		IsOn:      snap.State.active(),
+		Page:      snap.Page,
+		State:     string(snap.State),
+		JobID:     snap.JobID,
+		LastError: snap.LastError,
+	}
+	if !snap.StartedAt.IsZero() {
+		resp.StartedAt = &snap.StartedAt
+	}
+	if !snap.FinishedAt.IsZero() {
+		resp.FinishedAt = &snap.FinishedAt
+	}
+	if snap.Pipeline != nil {
+		resp.Pipeline = snap.Pipeline.report()
 	}
-}
 
-// OPTIMIZED: Simplified page fetching
-func (s *userService) fetchRankingPage(page int) (*ResponseGlobal, error) {
-	var out ResponseGlobal
-	if err := s.leetCodeClient.doGraphQL(queryGlobalRanking, map[string]interface{}{"page": page}, &out); err != nil {
+	job, err := s.storage.GetLatestSyncJob(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Errorf("GetSyncStatus: err=%v", err)
 		return nil, err
 	}
-	if len(out.Errors) > 0 {
-		return nil, fmt.Errorf("GraphQL errors: %+v", out.Errors)
+	if err == nil {
+		resp.Job = &job
 	}
-	return &out, nil
+	return resp, nil
+}
+
+// OPTIMIZED: Simplified page fetching
+func (s *userService) fetchRankingPage(ctx context.Context, page int) (*ResponseGlobal, error) {
+	return s.source.FetchRankingPage(ctx, s.retry.page, page)
 }
 
 // extractUsernamesFromPage extracts unique usernames from a page response
@@ -505,34 +764,108 @@ func (s *userService) extractUsernamesFromPage(pageResp *ResponseGlobal) []strin
 	return usernames
 }
 
+// rankingNodesByUsername indexes the ranking nodes of a page by username
+func rankingNodesByUsername(pageResp *ResponseGlobal) map[string]RankingNode {
+	nodes := make(map[string]RankingNode, len(pageResp.Data.GlobalRanking.RankingNodes))
+	for _, node := range pageResp.Data.GlobalRanking.RankingNodes {
+		username := strings.TrimSpace(node.User.Username)
+		if username == "" {
+			continue
+		}
+		if _, exists := nodes[username]; !exists {
+			nodes[username] = node
+		}
+	}
+	return nodes
+}
+
+// applyRankingNode copies contest rating, global rank and data region onto the user record
+func applyRankingNode(user *models.StageUserDataParams, node RankingNode) {
+	if rating, err := strconv.ParseFloat(strings.TrimSpace(node.CurrentRating), 64); err == nil {
+		user.ContestRating = sql.NullFloat64{Float64: rating, Valid: true}
+	}
+	if node.CurrentGlobalRank > 0 {
+		user.GlobalRanking = sql.NullInt32{Int32: int32(node.CurrentGlobalRank), Valid: true}
+	}
+	if ranking := strings.TrimSpace(node.Ranking); ranking != "" {
+		user.ContestRankings = sql.NullString{String: ranking, Valid: true}
+	}
+	if region := strings.TrimSpace(node.DataRegion); region != "" {
+		user.DataRegion = sql.NullString{String: region, Valid: true}
+	}
+}
+
 // SIMPLIFIED: Single method for external API calls (replaces FetchLeetCodeUser)
 func (s *userService) GetUserData(ctx context.Context, username string) (*models.StageUserDataParams, error) {
-	return s.fetchAndConvertUser(username)
+	return s.fetchAndConvertUser(ctx, s.retry.interactive, username)
 }
 
 // Core GraphQL execution method (unchanged but renamed for clarity)
-func (c *LeetCodeClient) doGraphQL(query string, variables map[string]interface{}, out interface{}) error {
+// doGraphQL sends the query, retrying throttled and transient failures under policy.
+// The returned error is classified (see errors_.ClassOf) unless ctx ended first.
+func (c *LeetCodeClient) doGraphQL(ctx context.Context, policy RetryPolicy, query string, variables map[string]interface{}, out interface{}) error {
+	for attempt := 1; ; attempt++ {
+		err := c.doGraphQLOnce(ctx, query, variables, out)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors_.IsRetryable(err) || attempt >= policy.attempts() {
+			return err
+		}
+
+		wait := policy.backoff(attempt, errors_.RetryAfterOf(err))
+		log.Printf("leetcode request failed (attempt %d/%d), retrying in %s: %v", attempt, policy.attempts(), wait, err)
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *LeetCodeClient) doGraphQLOnce(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
 	reqBody := GraphQLRequest{Query: query, Variables: variables}
 	payload, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", leetcodeURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("new request: %w", err)
 	}
 	req.Header = c.headers.Clone()
 
+	// Every caller (sync workers, CreateUser, GetUserData) shares one budget
+	if err := c.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("http do: %w", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// a replayed run must not retry its way past a missing recording
+		if errors.Is(err, cassette.ErrNoRecording) {
+			return err
+		}
+		return errors_.NewLeetCodeError(errors_.ClassTransient, 0, fmt.Errorf("http do: %w", err))
 	}
 	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusForbidden:
+		c.limiter.OnThrottle()
+		log.Printf("leetcode throttled us (status=%d), rate lowered to %.2f req/s", resp.StatusCode, c.limiter.Rate())
+	case http.StatusOK:
+		c.limiter.OnSuccess()
+	}
+
 	body, err := decompressResponse(resp)
 	if err != nil {
-		return fmt.Errorf("decompress: %w", err)
+		return errors_.NewLeetCodeError(errors_.ClassTransient, resp.StatusCode, fmt.Errorf("decompress: %w", err))
 	}
 
 	if c.debug {
@@ -540,15 +873,33 @@ func (c *LeetCodeClient) doGraphQL(query string, variables map[string]interface{
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non-200: %d body: %s", resp.StatusCode, truncate(string(body), 400))
+		lcErr := errors_.NewLeetCodeError(classifyStatus(resp.StatusCode), resp.StatusCode,
+			fmt.Errorf("non-200 body: %s", truncate(string(body), 400)))
+		lcErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return lcErr
 	}
 
 	if err := json.Unmarshal(body, &out); err != nil {
-		return fmt.Errorf("unmarshal: %w", err)
+		return errors_.NewLeetCodeError(errors_.ClassSchemaChange, resp.StatusCode, fmt.Errorf("unmarshal: %w", err))
 	}
 	return nil
 }
 
+// classifyStatus maps a non-200 status to an error class
+func classifyStatus(status int) errors_.ErrorClass {
+	switch {
+	case status == http.StatusTooManyRequests || status == http.StatusForbidden:
+		return errors_.ClassThrottled
+	case status == http.StatusRequestTimeout || status >= 500:
+		return errors_.ClassTransient
+	case status == http.StatusBadRequest || status == http.StatusNotFound:
+		// GraphQL rejects queries that no longer validate against the schema with 400
+		return errors_.ClassSchemaChange
+	default:
+		return errors_.ClassGraphQL
+	}
+}
+
 func decompressResponse(resp *http.Response) ([]byte, error) {
 	var reader io.Reader = resp.Body
 	switch resp.Header.Get("Content-Encoding") {
@@ -565,6 +916,21 @@ func decompressResponse(resp *http.Response) ([]byte, error) {
 	return io.ReadAll(reader)
 }
 
+// sleepCtx waits for d or until ctx is done, whichever comes first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
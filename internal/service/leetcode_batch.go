@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+// matchedUserFields is the matchedUser selection of queryMatchedUser, repeated
+// under every alias of a batch query
+const matchedUserFields = `{
+	  submitStats {
+		acSubmissionNum { difficulty count submissions }
+		totalSubmissionNum { difficulty count submissions }
+	  }
+	  profile { userSlug userAvatar countryCode countryName realName __typename }
+	}`
+
+// UserResult is the outcome of one username of a batch
+type UserResult struct {
+	Username string
+	User     *MatchedUser
+	Err      error
+}
+
+// responseUsers holds a batch query's data keyed by alias (u0, u1, ...)
+type responseUsers struct {
+	Data   map[string]*MatchedUser `json:"data"`
+	Errors []GraphQLError          `json:"errors,omitempty"`
+}
+
+// FetchMatchedUsers fetches many users in one request by aliasing matchedUser
+// once per username. An error LeetCode reports for one alias only fails that
+// user. When LeetCode rejects the batch query itself (a GraphQL or schema
+// error, e.g. a query too large), every user is fetched on its own, so one bad
+// query does not lose the whole batch. A throttled or transient failure is
+// returned for every user instead: single queries would only multiply the
+// requests sent to a LeetCode that is already pushing back.
+// Results are in the order of usernames.
+func (c *LeetCodeClient) FetchMatchedUsers(ctx context.Context, policy RetryPolicy, usernames []string) []UserResult {
+	if len(usernames) == 1 {
+		user, err := c.FetchMatchedUser(ctx, policy, usernames[0])
+		return []UserResult{{Username: usernames[0], User: user, Err: err}}
+	}
+
+	results, err := c.fetchMatchedUsersBatch(ctx, policy, usernames)
+	if err == nil {
+		return results
+	}
+
+	results = make([]UserResult, len(usernames))
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if class := errors_.ClassOf(err); class != errors_.ClassGraphQL && class != errors_.ClassSchemaChange {
+		for i, username := range usernames {
+			results[i] = UserResult{Username: username, Err: err}
+		}
+		return results
+	}
+
+	log.Printf("leetcode batch of %d users failed, falling back to single queries: %v", len(usernames), err)
+	for i, username := range usernames {
+		user, err := c.FetchMatchedUser(ctx, policy, username)
+		results[i] = UserResult{Username: username, User: user, Err: err}
+	}
+	return results
+}
+
+func (c *LeetCodeClient) fetchMatchedUsersBatch(ctx context.Context, policy RetryPolicy, usernames []string) ([]UserResult, error) {
+	query, variables := buildMatchedUsersQuery(usernames)
+
+	var out responseUsers
+	if err := c.doGraphQL(ctx, policy, query, variables, &out); err != nil {
+		return nil, fmt.Errorf("leetcode batch fetch of %d users failed: %w", len(usernames), err)
+	}
+	if len(out.Data) == 0 {
+		return nil, errors_.NewLeetCodeError(errors_.ClassGraphQL, 0, fmt.Errorf("batch of %d users returned no data: %+v", len(usernames), out.Errors))
+	}
+
+	// Errors carry the alias as the first path element; the rest apply to the whole query
+	byAlias := make(map[string][]GraphQLError)
+	var unattributed []GraphQLError
+	for _, e := range out.Errors {
+		if alias := e.alias(); alias != "" {
+			byAlias[alias] = append(byAlias[alias], e)
+		} else {
+			unattributed = append(unattributed, e)
+		}
+	}
+
+	results := make([]UserResult, len(usernames))
+	for i, username := range usernames {
+		alias := userAlias(i)
+		errs := byAlias[alias]
+		if len(errs) == 0 && out.Data[alias] == nil {
+			errs = unattributed
+		}
+		user, err := matchedUserOrError(username, out.Data[alias], errs)
+		results[i] = UserResult{Username: username, User: user, Err: err}
+	}
+	return results, nil
+}
+
+// buildMatchedUsersQuery aliases matchedUser as u0..uN-1. Usernames are passed
+// as variables, never spliced into the query text.
+func buildMatchedUsersQuery(usernames []string) (string, map[string]interface{}) {
+	variables := make(map[string]interface{}, len(usernames))
+	params := make([]string, len(usernames))
+	var fields strings.Builder
+	for i, username := range usernames {
+		alias := userAlias(i)
+		variables[alias] = username
+		params[i] = fmt.Sprintf("$%s: String!", alias)
+		fmt.Fprintf(&fields, "\t%s: matchedUser(username: $%s) %s\n", alias, alias, matchedUserFields)
+	}
+	return fmt.Sprintf("query userProfilesBatch(%s) {\n%s}", strings.Join(params, ", "), fields.String()), variables
+}
+
+func userAlias(i int) string {
+	return fmt.Sprintf("u%d", i)
+}
+
+// alias returns the top level field (our alias) the error is about, if any
+func (e GraphQLError) alias() string {
+	if len(e.Path) == 0 {
+		return ""
+	}
+	alias, _ := e.Path[0].(string)
+	return alias
+}
+
+// fetchAndConvertUsers fetches usernames with one batch query and converts them.
+// Failures of a cancelled ctx are dropped, the caller checks ctx itself.
+func (s *userService) fetchAndConvertUsers(ctx context.Context, policy RetryPolicy, usernames []string) ([]*models.StageUserDataParams, []userFetchFailure) {
+	var users []*models.StageUserDataParams
+	var failures []userFetchFailure
+
+	for _, r := range s.source.FetchMatchedUsers(ctx, policy, usernames) {
+		err := r.Err
+		var user *models.StageUserDataParams
+		if err == nil {
+			user, err = s.convertMatchedUser(r.Username, r.User)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			s.logger.Error("failed to fetch user", map[string]any{"username": r.Username, "error": err})
+			failures = append(failures, userFetchFailure{Username: r.Username, Err: err})
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, failures
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+const leaseWriteTimeout = 5 * time.Second
+
+// errLeaseLost cancels a lease that was taken over by another replica or whose
+// job is no longer running (paused, cancelled or closed).
+var errLeaseLost = errors.New("page lease lost")
+
+// StartLeaseWorker lets this replica work on distributed sync jobs. The worker
+// claims one page lease at a time from sync_page_leases, whichever replica
+// created the job, and stops with the service lifecycle.
+func (s *userService) StartLeaseWorker() {
+	s.syncWG.Add(1)
+	go func() {
+		defer s.syncWG.Done()
+		s.logger.Infof("sync: lease worker %s started", s.cfg.Leases.ReplicaID)
+
+		for {
+			worked := s.workNextLease(s.syncCtx)
+			if s.syncCtx.Err() != nil {
+				return
+			}
+			if worked {
+				continue
+			}
+
+			timer := time.NewTimer(s.cfg.Leases.PollInterval)
+			select {
+			case <-s.syncCtx.Done():
+				timer.Stop()
+				return
+			case <-s.leaseWake:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// wakeLeaseWorker makes the local worker look for leases without waiting for its next poll
+func (s *userService) wakeLeaseWorker() {
+	select {
+	case s.leaseWake <- struct{}{}:
+	default:
+	}
+}
+
+// workNextLease claims and works one lease. It reports whether there was one.
+func (s *userService) workNextLease(ctx context.Context) bool {
+	owner := s.cfg.Leases.ReplicaID
+
+	c, cancel := context.WithTimeout(ctx, leaseWriteTimeout)
+	lease, err := s.storage.ClaimPageLease(c, users_storage.ClaimPageLeaseParams{
+		Owner:      owner,
+		TtlSeconds: int32(s.cfg.Leases.TTL / time.Second),
+	})
+	cancel()
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Errorf("sync: lease worker %s: claim failed: %v", owner, err)
+		}
+		return false
+	}
+
+	c, cancel = context.WithTimeout(ctx, leaseWriteTimeout)
+	job, err := s.storage.GetSyncJob(c, lease.JobID)
+	cancel()
+	if err != nil {
+		s.logger.Errorf("sync: lease %d: could not load job %d: %v", lease.ID, lease.JobID, err)
+		s.releasePageLease(lease.ID)
+		return false
+	}
+
+	if lease.Attempts > 1 {
+		s.logger.Infof("sync: lease %d (pages %d-%d) taken over at page %d, attempt %d",
+			lease.ID, lease.StartPage, lease.EndPage, lease.CheckpointPage+1, lease.Attempts)
+	}
+	s.runLease(ctx, &job, &lease)
+	return true
+}
+
+// runLease sweeps the pages of a claimed lease from its checkpoint while a
+// heartbeat keeps it alive. Losing the lease stops the sweep after the page in flight.
+func (s *userService) runLease(ctx context.Context, job *users_storage.SyncJob, lease *users_storage.SyncPageLease) {
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		s.heartbeatLease(leaseCtx, cancel, lease.ID)
+	}()
+	defer func() { <-heartbeatDone }()
+	defer cancel(nil)
+
+	opts := leaseSyncOptions(job)
+	endPage := int(lease.EndPage)
+	processed := 0
+
+	s.logger.Info("sync: lease claimed", map[string]any{
+		"lease_id": lease.ID,
+		"job_id":   job.ID,
+		"pages":    fmt.Sprintf("%d-%d", lease.CheckpointPage+1, endPage),
+		"owner":    s.cfg.Leases.ReplicaID,
+	})
+
+	for page := int(lease.CheckpointPage) + 1; page <= endPage; page++ {
+		res, err := s.syncPage(leaseCtx, job.ID, page, nil, opts)
+		if leaseCtx.Err() != nil {
+			// shutdown, pause or takeover: whatever is left is claimable again
+			s.releasePageLease(lease.ID)
+			s.logger.Infof("sync: lease %d stopped before page %d: %v", lease.ID, page, context.Cause(leaseCtx))
+			return
+		}
+		if err != nil {
+			s.failPageLease(lease.ID, err)
+			s.recordSyncJobError(job.ID, 1, err)
+			s.logger.Errorf("sync: lease %d failed at page %d: %v", lease.ID, page, err)
+			s.finishLeasedSyncJob(job.ID)
+			return
+		}
+
+		processed += res.processed
+		s.checkpointPageLease(lease.ID, page)
+		s.addSyncJobProgress(job.ID, res)
+
+		if page < endPage && opts.Delay > 0 {
+			if err := sleepCtx(leaseCtx, opts.Delay); err != nil {
+				s.releasePageLease(lease.ID)
+				return
+			}
+		}
+	}
+
+	s.completePageLease(lease.ID)
+	s.logger.Infof("sync: lease %d (pages %d-%d) done, processed %d users", lease.ID, lease.StartPage, endPage, processed)
+	s.finishLeasedSyncJob(job.ID)
+}
+
+// heartbeatLease renews the lease until ctx ends, and cancels it once a renewal
+// finds the lease gone. A failed renewal is retried on the next beat; if the
+// database stays away the lease expires and another replica takes it over.
+func (s *userService) heartbeatLease(ctx context.Context, cancel context.CancelCauseFunc, leaseID int64) {
+	ticker := time.NewTicker(s.cfg.Leases.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c, cancelWrite := context.WithTimeout(ctx, leaseWriteTimeout)
+		renewed, err := s.storage.RenewPageLease(c, users_storage.RenewPageLeaseParams{
+			TtlSeconds: int32(s.cfg.Leases.TTL / time.Second),
+			ID:         leaseID,
+			Owner:      s.cfg.Leases.ReplicaID,
+		})
+		cancelWrite()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warnf("sync: lease %d: heartbeat failed: %v", leaseID, err)
+			}
+			continue
+		}
+		if renewed == 0 {
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+// coordinateLeasedSync splits the pages of a distributed job into leases and waits
+// while the lease workers of every replica, this one included, sweep them. The
+// job is closed by whichever worker closes its last lease.
+func (s *userService) coordinateLeasedSync(ctx context.Context, job *users_storage.SyncJob, startPage, endPage int) error {
+	c, cancel := context.WithTimeout(ctx, leaseWriteTimeout)
+	err := s.storage.CreatePageLeases(c, users_storage.CreatePageLeasesParams{
+		JobID:      job.ID,
+		LeasePages: max(job.LeasePages, 1),
+		EndPage:    int32(endPage),
+		StartPage:  int32(startPage),
+	})
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return s.failSyncJob(job.ID, startPage, fmt.Errorf("create page leases: %w", err))
+	}
+	s.logger.Infof("sync: job %d leased pages %d to %d in ranges of %d pages", job.ID, startPage, endPage, job.LeasePages)
+
+	// a resumed job may have nothing left to lease
+	s.finishLeasedSyncJob(job.ID)
+	s.wakeLeaseWorker()
+
+	ticker := time.NewTicker(s.cfg.Leases.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.controller.waitIfPaused(ctx); err != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		c, cancel := context.WithTimeout(ctx, leaseWriteTimeout)
+		current, err := s.storage.GetSyncJob(c, job.ID)
+		cancel()
+		if err != nil {
+			s.logger.Warnf("sync: job %d: could not check progress: %v", job.ID, err)
+			continue
+		}
+
+		switch current.Status {
+		case SyncJobFinished:
+			s.logger.Infof("sync: distributed job %d completed. Total processed users: %d", job.ID, current.ProcessedUsers)
+			return nil
+		case SyncJobFailed:
+			return fmt.Errorf("sync job %d failed: %s", job.ID, current.LastError.String)
+		case SyncJobStopped:
+			return context.Canceled
+		}
+	}
+
+	// Shutdown leaves the job to the replicas still running; cancel stops it
+	// everywhere, their heartbeats find it no longer running.
+	s.leaveSyncJob(ctx, job.ID)
+	s.logger.Infof("sync: stopped coordinating distributed job %d: %v", job.ID, ctx.Err())
+	return ctx.Err()
+}
+
+// leaseSyncOptions rebuilds the options a distributed job was started with
+func leaseSyncOptions(job *users_storage.SyncJob) SyncOptions {
+	opts := SyncOptions{
+		Workers:     int(job.Workers),
+		BatchSize:   int(job.BatchSize),
+		Delay:       time.Duration(job.DelayMs) * time.Millisecond,
+		JobID:       job.ID,
+		Mode:        SyncMode(job.Mode),
+		MaxAge:      time.Duration(job.MaxAgeSeconds) * time.Second,
+		Distributed: true,
+		DryRun:      job.DryRun,
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 3
+	}
+	if opts.Mode == "" {
+		opts.Mode = SyncModeFull
+	}
+	if opts.Mode == SyncModeIncremental && opts.MaxAge <= 0 {
+		opts.MaxAge = defaultIncrementalMaxAge
+	}
+	return opts
+}
+
+func (s *userService) checkpointPageLease(leaseID int64, page int) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseWriteTimeout)
+	defer cancel()
+
+	if _, err := s.storage.CheckpointPageLease(ctx, users_storage.CheckpointPageLeaseParams{
+		CheckpointPage: int32(page),
+		ID:             leaseID,
+		Owner:          s.cfg.Leases.ReplicaID,
+	}); err != nil {
+		s.logger.Errorf("sync: lease %d: failed to checkpoint page %d: %v", leaseID, page, err)
+	}
+}
+
+func (s *userService) completePageLease(leaseID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseWriteTimeout)
+	defer cancel()
+
+	completed, err := s.storage.CompletePageLease(ctx, users_storage.CompletePageLeaseParams{
+		ID:    leaseID,
+		Owner: s.cfg.Leases.ReplicaID,
+	})
+	if err != nil {
+		s.logger.Errorf("sync: lease %d: failed to mark as done: %v", leaseID, err)
+		return
+	}
+	if completed == 0 {
+		s.logger.Warnf("sync: lease %d was taken over before it could be closed", leaseID)
+	}
+}
+
+func (s *userService) releasePageLease(leaseID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.ReleasePageLease(ctx, users_storage.ReleasePageLeaseParams{
+		ID:    leaseID,
+		Owner: s.cfg.Leases.ReplicaID,
+	}); err != nil {
+		s.logger.Errorf("sync: lease %d: failed to release: %v", leaseID, err)
+	}
+}
+
+func (s *userService) failPageLease(leaseID int64, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.FailPageLease(ctx, users_storage.FailPageLeaseParams{
+		MaxAttempts: int32(s.cfg.Leases.MaxAttempts),
+		LastError:   sql.NullString{String: cause.Error(), Valid: true},
+		ID:          leaseID,
+		Owner:       s.cfg.Leases.ReplicaID,
+	}); err != nil {
+		s.logger.Errorf("sync: lease %d: failed to record error %q: %v", leaseID, cause, err)
+	}
+}
+
+func (s *userService) addSyncJobProgress(jobID int64, res pageResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.AddSyncJobProgress(ctx, users_storage.AddSyncJobProgressParams{
+		ProcessedUsers: int32(res.processed),
+		FailedUsers:    int32(res.failed),
+		SkippedUsers:   int32(res.skipped),
+		ID:             jobID,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to count page progress: %v", jobID, err)
+	}
+}
+
+// finishLeasedSyncJob closes the job if no lease is left to work on
+func (s *userService) finishLeasedSyncJob(jobID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	finished, err := s.storage.FinishLeasedSyncJob(ctx, jobID)
+	if err != nil {
+		s.logger.Errorf("sync: job %d: failed to close: %v", jobID, err)
+		return
+	}
+	if finished > 0 {
+		s.logger.Infof("sync: distributed job %d closed, no leases left", jobID)
+	}
+}
+
+func (s *userService) ListSyncJobLeases(ctx context.Context, jobID int64) ([]users_storage.SyncPageLease, error) {
+	if _, err := s.storage.GetSyncJob(ctx, jobID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors_.ErrSyncJobNotFound
+		}
+		s.logger.Errorf("ListSyncJobLeases: job_id=%d err=%v", jobID, err)
+		return nil, err
+	}
+
+	leases, err := s.storage.ListPageLeases(ctx, jobID)
+	if err != nil {
+		s.logger.Errorf("ListSyncJobLeases: job_id=%d err=%v", jobID, err)
+		return nil, err
+	}
+	return leases, nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+// rankingPageUsers builds user records from a ranking page alone, for
+// SyncModeRanking. Profile, country, rating and rank are all on the page, so
+// no queryMatchedUser call is needed; solved and submission counts are not and
+// stay zero, UpsertRankingData never writes them over stored ones.
+func rankingPageUsers(usernames []string, nodes map[string]RankingNode) []*models.StageUserDataParams {
+	users := make([]*models.StageUserDataParams, 0, len(usernames))
+	for _, username := range usernames {
+		node, ok := nodes[username]
+		if !ok {
+			continue
+		}
+		profile := node.User.Profile
+		user := &models.StageUserDataParams{
+			Username:    username,
+			UserSlug:    profile.UserSlug,
+			UserAvatar:  profile.UserAvatar,
+			CountryCode: profile.CountryCode,
+			CountryName: profile.CountryName,
+			RealName:    profile.RealName,
+			Typename:    profile.Typename,
+		}
+		if user.UserSlug == "" {
+			user.UserSlug = username
+		}
+		applyRankingNode(user, node)
+		users = append(users, user)
+	}
+	return users
+}
+
+// upsertSyncedUsers writes the users of a sync. A ranking sync only carries
+// ranking page fields, so it must not go through UpsertUserData, which would
+// zero the solved counts and mark the stats as fetched. A dry run writes
+// nothing and reports what would have changed instead.
+func (s *userService) upsertSyncedUsers(ctx context.Context, jobID int64, opts SyncOptions, users []*models.StageUserDataParams) error {
+	if opts.DryRun {
+		return s.recordDryRunChanges(ctx, jobID, opts.Mode, users)
+	}
+	if opts.Mode == SyncModeRanking {
+		return s.dbStorage.UpsertRankingData(ctx, users)
+	}
+	if err := s.dbStorage.UpsertUserData(ctx, users); err != nil {
+		return err
+	}
+	s.clearFailedFetches(users)
+	return nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// adaptiveLimiter is a token bucket shared by every LeetCode request.
+// The refill rate is cut multiplicatively whenever LeetCode throttles us and
+// grows back additively after a run of successful responses.
+type adaptiveLimiter struct {
+	mu           sync.Mutex
+	rate         float64 // current tokens per second
+	maxRate      float64
+	minRate      float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	successes    int
+	recoverAfter int     // successful responses needed before speeding up
+	recoverStep  float64 // tokens per second added on each speed-up
+}
+
+func newAdaptiveLimiter(rps float64, burst int, minRPS float64, recoverAfter int) *adaptiveLimiter {
+	if rps <= 0 {
+		rps = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if minRPS <= 0 || minRPS > rps {
+		minRPS = math.Min(rps, 0.1)
+	}
+	if recoverAfter < 1 {
+		recoverAfter = 1
+	}
+	return &adaptiveLimiter{
+		rate:         rps,
+		maxRate:      rps,
+		minRate:      minRPS,
+		burst:        float64(burst),
+		tokens:       float64(burst),
+		last:         time.Now(),
+		recoverAfter: recoverAfter,
+		recoverStep:  math.Max(rps/10, minRPS),
+	}
+}
+
+// refill must be called with mu held
+func (l *adaptiveLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	if elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *adaptiveLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	l.refill(time.Now())
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := sleepCtx(ctx, wait); err != nil {
+		// hand the reserved token back
+		l.mu.Lock()
+		l.tokens = math.Min(l.burst, l.tokens+1)
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// OnThrottle halves the rate and drains the bucket after a 429/403
+func (l *adaptiveLimiter) OnThrottle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.rate = math.Max(l.minRate, l.rate/2)
+	l.tokens = math.Min(l.tokens, 0)
+	l.successes = 0
+}
+
+// OnSuccess nudges the rate back towards the configured budget
+func (l *adaptiveLimiter) OnSuccess() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate >= l.maxRate {
+		return
+	}
+	l.successes++
+	if l.successes >= l.recoverAfter {
+		l.refill(time.Now())
+		l.rate = math.Min(l.maxRate, l.rate+l.recoverStep)
+		l.successes = 0
+	}
+}
+
+// Rate returns the current requests-per-second budget
+func (l *adaptiveLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
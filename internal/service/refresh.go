@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+// refreshUsers fetches usernames outside of a page sweep and stores the ones that
+// succeed. Failures are returned for the caller to record. It does not wait on a
+// paused sweep, so scheduled refreshes and retries keep their cadence.
+func (s *userService) refreshUsers(ctx context.Context, usernames []string, workers int) ([]*models.StageUserDataParams, []userFetchFailure, error) {
+	users, failures, err := s.processUsersConcurrently(ctx, usernames, workers, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(users) > 0 {
+		if err := s.dbStorage.UpsertUserData(ctx, users); err != nil {
+			return nil, nil, fmt.Errorf("upsert %d users: %w", len(users), err)
+		}
+		s.clearFailedFetches(users)
+	}
+	return users, failures, nil
+}
+
+// Refresh priority tiers, see user_refresh_tiers
+const (
+	RefreshTierManual   = "manual"
+	RefreshTierTracked  = "tracked"
+	RefreshTierTopN     = "top_n"
+	RefreshTierLongTail = "long_tail"
+)
+
+type overdueUser struct {
+	username string
+	tier     string
+	dueAt    time.Time // zero for users never fetched
+}
+
+// recomputeRefreshTiers rebuilds the tracked and top_n tiers. It ranks every
+// stored user, so it runs after a page sweep changed their totals rather than
+// on every priority refresh pass.
+func (s *userService) recomputeRefreshTiers(ctx context.Context) {
+	if err := s.storage.RecomputeRefreshTiers(ctx, int32(s.cfg.RefreshTiers.TopN)); err != nil {
+		s.logger.Errorf("sync: recompute refresh tiers: %v", err)
+	}
+}
+
+// RefreshOverdueUsers re-fetches up to RefreshTiers.BatchSize users whose tier
+// interval has elapsed, most overdue first. The tiers themselves are
+// recomputed after every page sweep.
+func (s *userService) RefreshOverdueUsers(ctx context.Context) error {
+	cfg := s.cfg.RefreshTiers
+	overdue, err := s.overdueUsers(ctx, cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(overdue) == 0 {
+		return nil
+	}
+
+	usernames := make([]string, 0, len(overdue))
+	perTier := make(map[string]int)
+	for _, u := range overdue {
+		usernames = append(usernames, u.username)
+		perTier[u.tier]++
+	}
+
+	users, failures, err := s.refreshUsers(ctx, usernames, s.cfg.Scheduler.SyncWorkers)
+	if err != nil {
+		return fmt.Errorf("refresh overdue users: %w", err)
+	}
+	s.recordFailedFetches(0, 0, failures)
+
+	s.logger.Info("priority refresh: pass finished", map[string]any{
+		"picked":    len(usernames),
+		"per_tier":  perTier,
+		"refreshed": len(users),
+		"failed":    len(failures),
+	})
+	return nil
+}
+
+// overdueUsers collects up to limit overdue users from every tier and orders
+// them by how long ago they became due
+func (s *userService) overdueUsers(ctx context.Context, limit int) ([]overdueUser, error) {
+	cfg := s.cfg.RefreshTiers
+	now := time.Now()
+	var overdue []overdueUser
+
+	tiers := []struct {
+		tier     string
+		interval time.Duration
+	}{
+		{RefreshTierManual, cfg.ManualInterval},
+		{RefreshTierTracked, cfg.TrackedInterval},
+		{RefreshTierTopN, cfg.TopNInterval},
+	}
+	for _, t := range tiers {
+		if t.interval <= 0 {
+			continue
+		}
+		rows, err := s.storage.ListOverdueUsersByTier(ctx, users_storage.ListOverdueUsersByTierParams{
+			Tier:            t.tier,
+			RefreshedBefore: now.Add(-t.interval),
+			LimitCount:      int32(limit),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list overdue %s users: %w", t.tier, err)
+		}
+		for _, row := range rows {
+			u := overdueUser{username: row.Username, tier: t.tier}
+			if row.StatsFetchedAt.Valid {
+				u.dueAt = row.StatsFetchedAt.Time.Add(t.interval)
+			}
+			overdue = append(overdue, u)
+		}
+	}
+
+	if cfg.LongTailInterval > 0 {
+		rows, err := s.storage.ListOverdueLongTailUsers(ctx, users_storage.ListOverdueLongTailUsersParams{
+			RefreshedBefore: now.Add(-cfg.LongTailInterval),
+			LimitCount:      int32(limit),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list overdue long tail users: %w", err)
+		}
+		for _, row := range rows {
+			u := overdueUser{username: row.Username, tier: RefreshTierLongTail}
+			if row.StatsFetchedAt.Valid {
+				u.dueAt = row.StatsFetchedAt.Time.Add(cfg.LongTailInterval)
+			}
+			overdue = append(overdue, u)
+		}
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool { return overdue[i].dueAt.Before(overdue[j].dueAt) })
+	if len(overdue) > limit {
+		overdue = overdue[:limit]
+	}
+	return overdue, nil
+}
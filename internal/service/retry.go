@@ -0,0 +1,68 @@
+package service
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryAfter caps how long a single Retry-After may hold a caller
+const maxRetryAfter = 5 * time.Minute
+
+// RetryPolicy is the retry budget of one call site
+type RetryPolicy struct {
+	MaxAttempts int // total attempts, including the first one
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// backoff returns how long to wait after the given failed attempt (1-based).
+// The delay doubles every attempt up to MaxDelay and half of it is jittered, so
+// workers that failed together do not come back together. A server supplied
+// Retry-After wins when it is longer.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+
+	if retryAfter > d {
+		d = min(retryAfter, maxRetryAfter)
+	}
+	return d
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// parseRetryAfter understands both forms of the header: delay-seconds and HTTP-date
+func parseRetryAfter(h string) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+)
+
+// LeetCodeSource is where the service gets LeetCode data from. LeetCodeClient
+// queries leetcode.com; a cache in front of it, a fixture-backed fake or a
+// leetcode.cn client can be provided through fx instead, the service only sees
+// this interface. Every call takes the retry budget of its call site, a source
+// that does not retry may ignore it.
+type LeetCodeSource interface {
+	// FetchRankingPage returns one page (1-based) of the global contest ranking
+	FetchRankingPage(ctx context.Context, policy RetryPolicy, page int) (*ResponseGlobal, error)
+	// FetchMatchedUser returns the profile and solved stats of one user, or
+	// errors_.ErrUserNotAvailable when the user does not exist
+	FetchMatchedUser(ctx context.Context, policy RetryPolicy, username string) (*MatchedUser, error)
+	// FetchMatchedUsers is FetchMatchedUser for many users, one result per
+	// username in the order of usernames
+	FetchMatchedUsers(ctx context.Context, policy RetryPolicy, usernames []string) []UserResult
+	// UserBatchSize is how many users FetchMatchedUsers is best called with
+	UserBatchSize() int
+}
+
+// rateReporter is implemented by sources that pace their requests
+type rateReporter interface {
+	Rate() float64
+}
+
+// retryPolicies are the retry budgets of the service's call sites
+type retryPolicies struct {
+	page        RetryPolicy
+	user        RetryPolicy
+	interactive RetryPolicy // a client is waiting
+}
+
+func newRetryPolicies(cfg *config.Config) retryPolicies {
+	return retryPolicies{
+		page:        newRetryPolicy(cfg.PageMaxAttempts, cfg),
+		user:        newRetryPolicy(cfg.UserMaxAttempts, cfg),
+		interactive: newRetryPolicy(cfg.InteractiveMaxAttempts, cfg),
+	}
+}
+
+func newRetryPolicy(maxAttempts int, cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}
+}
+
+// userBatches splits usernames into chunks of the source's user batch size
+func (s *userService) userBatches(usernames []string) [][]string {
+	size := max(s.source.UserBatchSize(), 1)
+	batches := make([][]string, 0, (len(usernames)+size-1)/size)
+	for start := 0; start < len(usernames); start += size {
+		batches = append(batches, usernames[start:min(start+size, len(usernames))])
+	}
+	return batches
+}
+
+// sourceRate is the source's current request rate for logs, 0 when it does not pace
+func (s *userService) sourceRate() float64 {
+	if r, ok := s.source.(rateReporter); ok {
+		return r.Rate()
+	}
+	return 0
+}
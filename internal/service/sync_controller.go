@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// SyncState is the lifecycle state of the sync controller
+type SyncState string
+
+const (
+	SyncIdle       SyncState = "idle"
+	SyncRunning    SyncState = "running"
+	SyncPaused     SyncState = "paused"
+	SyncCancelling SyncState = "cancelling"
+	SyncFinished   SyncState = "finished"
+	SyncFailed     SyncState = "failed"
+)
+
+// active reports whether a sync job currently owns the controller
+func (st SyncState) active() bool {
+	return st == SyncRunning || st == SyncPaused || st == SyncCancelling
+}
+
+// SyncSnapshot is a consistent, point-in-time view of the controller
+type SyncSnapshot struct {
+	State      SyncState
+	JobID      int64
+	Page       int
+	LastError  string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Pipeline   *pipelineMetrics // stages of the current or last page sweep, nil before the first
+}
+
+// syncController serialises every state transition of the single sync job
+// this process may run. All fields are guarded by mu.
+type syncController struct {
+	mu         sync.Mutex
+	state      SyncState
+	jobID      int64
+	page       int
+	lastError  string
+	startedAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	resumed    chan struct{} // closed when a paused job may continue
+	pipeline   *pipelineMetrics
+}
+
+func newSyncController() *syncController {
+	return &syncController{state: SyncIdle}
+}
+
+// begin claims the controller for a new job. The returned context is cancelled
+// by cancelJob or when parent is done.
+func (c *syncController) begin(parent context.Context) (context.Context, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.active() {
+		return nil, fmt.Errorf("%w (state: %s)", errors_.ErrSyncInProgress, c.state)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	c.state = SyncRunning
+	c.jobID = 0
+	c.page = 0
+	c.lastError = ""
+	c.startedAt = time.Now()
+	c.finishedAt = time.Time{}
+	c.cancel = cancel
+	c.resumed = nil
+	c.pipeline = nil
+	return ctx, nil
+}
+
+// finish releases the controller once the job goroutine has returned
+func (c *syncController) finish(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.state == SyncCancelling:
+		c.state = SyncIdle
+	case errors.Is(err, context.Canceled):
+		c.state = SyncIdle
+	case err != nil:
+		c.state = SyncFailed
+		c.lastError = err.Error()
+	default:
+		c.state = SyncFinished
+	}
+	c.finishedAt = time.Now()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+func (c *syncController) pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != SyncRunning {
+		return fmt.Errorf("%w: cannot pause while %s", errors_.ErrInvalidSyncTransition, c.state)
+	}
+	c.state = SyncPaused
+	c.resumed = make(chan struct{})
+	return nil
+}
+
+func (c *syncController) resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != SyncPaused {
+		return fmt.Errorf("%w: cannot resume while %s", errors_.ErrInvalidSyncTransition, c.state)
+	}
+	c.state = SyncRunning
+	close(c.resumed)
+	c.resumed = nil
+	return nil
+}
+
+func (c *syncController) cancelJob() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != SyncRunning && c.state != SyncPaused {
+		return fmt.Errorf("%w: cannot cancel while %s", errors_.ErrInvalidSyncTransition, c.state)
+	}
+	c.state = SyncCancelling
+	c.cancel()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+	return nil
+}
+
+// waitIfPaused blocks while the job is paused. It returns ctx.Err() when the
+// job is cancelled, either while paused or before.
+func (c *syncController) waitIfPaused(ctx context.Context) error {
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *syncController) setJob(jobID int64) {
+	c.mu.Lock()
+	c.jobID = jobID
+	c.mu.Unlock()
+}
+
+func (c *syncController) setPage(page int) {
+	c.mu.Lock()
+	c.page = page
+	c.mu.Unlock()
+}
+
+func (c *syncController) setPipeline(m *pipelineMetrics) {
+	c.mu.Lock()
+	c.pipeline = m
+	c.mu.Unlock()
+}
+
+func (c *syncController) snapshot() SyncSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return SyncSnapshot{
+		State:      c.state,
+		JobID:      c.jobID,
+		Page:       c.page,
+		LastError:  c.lastError,
+		StartedAt:  c.startedAt,
+		FinishedAt: c.finishedAt,
+		Pipeline:   c.pipeline,
+	}
+}
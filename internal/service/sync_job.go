@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k0kubun/pp"
+	"github.com/lib/pq"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// Sync job statuses persisted in sync_jobs.status
+const (
+	SyncJobRunning  = "running"
+	SyncJobPaused   = "paused"
+	SyncJobStopped  = "stopped"
+	SyncJobFinished = "finished"
+	SyncJobFailed   = "failed"
+)
+
+const syncJobWriteTimeout = 5 * time.Second
+
+// errSyncJobLost stops a job another replica took over after this one's
+// heartbeat went stale, or that was closed behind its back
+var errSyncJobLost = errors.New("sync job lost")
+
+// startSyncJob creates a new sync job for opts, or reopens opts.JobID and rewrites
+// opts so the sweep continues right after the job's last committed page.
+func (s *userService) startSyncJob(opts *SyncOptions) (*users_storage.SyncJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if opts.JobID > 0 {
+		job, err := s.storage.ClaimSyncJob(ctx, users_storage.ClaimSyncJobParams{
+			Owner:        s.cfg.Leases.ReplicaID,
+			ID:           opts.JobID,
+			StaleSeconds: int32(s.cfg.Leases.TTL / time.Second),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			current, err := s.storage.GetSyncJob(ctx, opts.JobID)
+			switch {
+			case err != nil:
+				return nil, fmt.Errorf("get sync job %d: %w", opts.JobID, err)
+			case current.Status == SyncJobFinished:
+				return nil, fmt.Errorf("sync job %d is already finished", current.ID)
+			default:
+				return nil, fmt.Errorf("%w: job %d is running on replica %s", errors_.ErrSyncInProgress, current.ID, current.Owner.String)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("claim sync job %d: %w", opts.JobID, err)
+		}
+
+		opts.StartPage = int(job.CheckpointPage) + 1
+		if job.StartPage > job.CheckpointPage {
+			opts.StartPage = int(job.StartPage)
+		}
+		opts.Pages = 0
+		if job.EndPage > 0 {
+			opts.Pages = int(job.EndPage) - opts.StartPage + 1
+		}
+		opts.Workers = int(job.Workers)
+		opts.BatchSize = int(job.BatchSize)
+		opts.Delay = time.Duration(job.DelayMs) * time.Millisecond
+		opts.Mode = SyncMode(job.Mode)
+		opts.MaxAge = time.Duration(job.MaxAgeSeconds) * time.Second
+		opts.Distributed = job.Distributed
+		opts.Target = targetFromJob(&job)
+		opts.DryRun = job.DryRun
+
+		if job.Distributed {
+			// leases that ran out of attempts get a fresh budget, the rest keep their checkpoints
+			if err := s.storage.ResetFailedPageLeases(ctx, job.ID); err != nil {
+				return nil, fmt.Errorf("reset failed leases of sync job %d: %w", job.ID, err)
+			}
+		}
+
+		if job.Target != SyncTargetPages {
+			s.logger.Infof("sync: resuming %s job %d after %q", job.Target, job.ID, job.CheckpointUsername)
+		} else {
+			s.logger.Infof("sync: resuming job %d from page %d", job.ID, opts.StartPage)
+		}
+		return &job, nil
+	}
+
+	endPage := 0
+	if opts.Pages > 0 {
+		endPage = opts.StartPage + opts.Pages - 1
+	}
+	// target_usernames is NOT NULL and a nil slice would be sent as NULL
+	targetUsernames := opts.Target.Usernames
+	if targetUsernames == nil {
+		targetUsernames = []string{}
+	}
+	job, err := s.storage.CreateSyncJob(ctx, users_storage.CreateSyncJobParams{
+		StartPage:           int32(opts.StartPage),
+		EndPage:             int32(endPage),
+		CheckpointPage:      int32(opts.StartPage - 1),
+		Workers:             int32(opts.Workers),
+		BatchSize:           int32(opts.BatchSize),
+		DelayMs:             int32(opts.Delay / time.Millisecond),
+		Mode:                string(opts.Mode),
+		MaxAgeSeconds:       int32(opts.MaxAge / time.Second),
+		Distributed:         opts.Distributed,
+		LeasePages:          int32(max(s.cfg.Leases.PagesPerLease, 1)),
+		Target:              opts.Target.kind(),
+		TargetUsernames:     targetUsernames,
+		TargetCountry:       opts.Target.Country,
+		TargetUpdatedBefore: sql.NullTime{Time: opts.Target.NotUpdatedSince, Valid: !opts.Target.NotUpdatedSince.IsZero()},
+		Owner:               sql.NullString{String: s.cfg.Leases.ReplicaID, Valid: true},
+		DryRun:              opts.DryRun,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return nil, fmt.Errorf("%w: another replica is running a distributed sync", errors_.ErrSyncInProgress)
+		}
+		return nil, fmt.Errorf("create sync job: %w", err)
+	}
+	s.logger.Infof("sync: created %s job %d starting at page %d", job.Target, job.ID, opts.StartPage)
+	return &job, nil
+}
+
+func (s *userService) setSyncJobRange(jobID int64, endPage, totalPages int) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.SetSyncJobRange(ctx, users_storage.SetSyncJobRangeParams{
+		ID:         jobID,
+		EndPage:    int32(endPage),
+		TotalPages: int32(totalPages),
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to store page range: %v", jobID, err)
+	}
+}
+
+// checkpointSyncJob marks page as committed so a resumed job starts after it
+func (s *userService) checkpointSyncJob(jobID int64, page, processed, failed, skipped int) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.CheckpointSyncJob(ctx, users_storage.CheckpointSyncJobParams{
+		CheckpointPage: int32(page),
+		ProcessedUsers: int32(processed),
+		FailedUsers:    int32(failed),
+		SkippedUsers:   int32(skipped),
+		ID:             jobID,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to checkpoint page %d: %v", jobID, page, err)
+	}
+}
+
+// checkpointTargetedSyncJob marks every target user up to username as committed
+func (s *userService) checkpointTargetedSyncJob(jobID int64, username string, processed, failed int) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.CheckpointTargetedSyncJob(ctx, users_storage.CheckpointTargetedSyncJobParams{
+		CheckpointUsername: username,
+		ProcessedUsers:     int32(processed),
+		FailedUsers:        int32(failed),
+		ID:                 jobID,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to checkpoint user %q: %v", jobID, username, err)
+	}
+}
+
+func (s *userService) recordSyncJobError(jobID int64, failedPages int, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.RecordSyncJobError(ctx, users_storage.RecordSyncJobErrorParams{
+		FailedPages: int32(failedPages),
+		LastError:   sql.NullString{String: cause.Error(), Valid: true},
+		ID:          jobID,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to record error %q: %v", jobID, cause, err)
+	}
+}
+
+func (s *userService) setSyncJobStatus(jobID int64, status string) {
+	if jobID == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.SetSyncJobStatus(ctx, users_storage.SetSyncJobStatusParams{
+		ID:     jobID,
+		Status: status,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to set status %s: %v", jobID, status, err)
+	}
+}
+
+func (s *userService) finishSyncJob(jobID int64, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+	defer cancel()
+
+	if err := s.storage.FinishSyncJob(ctx, users_storage.FinishSyncJobParams{
+		ID:     jobID,
+		Status: status,
+	}); err != nil {
+		s.logger.Errorf("sync: job %d: failed to mark as %s: %v", jobID, status, err)
+	}
+}
+
+// heartbeatSyncJob keeps the job claimed by this replica until ctx ends, and
+// cancels it once a beat finds it taken over or closed. A failed beat is
+// retried on the next one; if the database stays away the heartbeat goes
+// stale and another replica resumes the job.
+func (s *userService) heartbeatSyncJob(ctx context.Context, cancel context.CancelCauseFunc, jobID int64) {
+	ticker := time.NewTicker(s.cfg.Leases.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c, cancelWrite := context.WithTimeout(ctx, syncJobWriteTimeout)
+		beats, err := s.storage.HeartbeatSyncJob(c, users_storage.HeartbeatSyncJobParams{
+			ID:    jobID,
+			Owner: s.cfg.Leases.ReplicaID,
+		})
+		cancelWrite()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warnf("sync: job %d: heartbeat failed: %v", jobID, err)
+			}
+			continue
+		}
+		if beats == 0 {
+			cancel(errSyncJobLost)
+			return
+		}
+	}
+}
+
+// leaveSyncJob records why ctx ended the job on this replica. A cancelled job
+// is closed as stopped and a job taken over is left to its new owner. On
+// shutdown the job keeps its status and is released, so a live replica resumes
+// it right after its last checkpoint.
+func (s *userService) leaveSyncJob(ctx context.Context, jobID int64) {
+	switch {
+	case errors.Is(context.Cause(ctx), errSyncJobLost):
+		s.logger.Warnf("sync: job %d was taken over by another replica", jobID)
+	case s.syncCtx.Err() == nil:
+		s.finishSyncJob(jobID, SyncJobStopped)
+	default:
+		c, cancel := context.WithTimeout(context.Background(), syncJobWriteTimeout)
+		defer cancel()
+		if err := s.storage.ReleaseSyncJob(c, users_storage.ReleaseSyncJobParams{
+			ID:    jobID,
+			Owner: s.cfg.Leases.ReplicaID,
+		}); err != nil {
+			s.logger.Errorf("sync: job %d: failed to release: %v", jobID, err)
+		}
+	}
+}
+
+// interruptSyncJob handles a job whose ctx ended, see leaveSyncJob
+func (s *userService) interruptSyncJob(ctx context.Context, jobID int64, processed int) error {
+	s.leaveSyncJob(ctx, jobID)
+	s.logger.Infof("sync: job %d interrupted: %v. Total processed users: %d", jobID, ctx.Err(), processed)
+	pp.Println("------------------ synchronization interrupted -----------------")
+	return ctx.Err()
+}
+
+// failSyncJob closes the job as failed without moving its checkpoint past page
+func (s *userService) failSyncJob(jobID int64, page int, cause error) error {
+	s.logger.Error("sync: job failed", map[string]any{
+		"job_id":      jobID,
+		"page":        page,
+		"error_class": errors_.ClassOf(cause),
+		"error":       cause.Error(),
+	})
+	s.recordSyncJobError(jobID, 1, cause)
+	s.finishSyncJob(jobID, SyncJobFailed)
+	return cause
+}
+
+// ResumeInterruptedSync restarts, in the background, the most recent job whose
+// replica went down while running it. A job whose owner still sends heartbeats
+// is left alone. While idle, this replica keeps looking for such jobs every
+// LeaseConfig.TTL, so a job left behind in a rolling restart is picked up by a
+// replica that is still alive.
+func (s *userService) ResumeInterruptedSync(ctx context.Context) error {
+	err := s.resumeInterruptedSync(ctx)
+
+	s.syncWG.Add(1)
+	go func() {
+		defer s.syncWG.Done()
+		ticker := time.NewTicker(s.cfg.Leases.TTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.syncCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			c, cancel := context.WithTimeout(s.syncCtx, syncJobWriteTimeout)
+			if err := s.resumeInterruptedSync(c); err != nil && s.syncCtx.Err() == nil {
+				s.logger.Errorf("sync: %v", err)
+			}
+			cancel()
+		}
+	}()
+	return err
+}
+
+func (s *userService) resumeInterruptedSync(ctx context.Context) error {
+	if s.controller.snapshot().State.active() {
+		return nil
+	}
+	job, err := s.storage.GetInterruptedSyncJob(ctx, int32(s.cfg.Leases.TTL/time.Second))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find interrupted sync job: %w", err)
+	}
+
+	s.logger.Infof("sync: found interrupted job %d (checkpoint page %d)", job.ID, job.CheckpointPage)
+	return s.StartSync(SyncOptions{JobID: job.ID})
+}
+
+func (s *userService) ListSyncJobs(ctx context.Context, arg *users_storage.ListSyncJobsParams) ([]users_storage.SyncJob, error) {
+	jobs, err := s.storage.ListSyncJobs(ctx, *arg)
+	if err != nil {
+		s.logger.Errorf("ListSyncJobs: params=%+v err=%v", arg, err)
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+)
+
+const (
+	defaultPageLookahead = 4
+	// writerFlushInterval bounds how long a partial batch waits for more pages
+	writerFlushInterval = 5 * time.Second
+	upsertTimeout       = 20 * time.Second
+)
+
+// Names of the sync pipeline stages, as reported by /sync-status
+const (
+	StageFetchPages = "fetch_pages"
+	StageFetchUsers = "fetch_users"
+	StageWrite      = "write"
+)
+
+// stageMetrics counts the work of one pipeline stage. busy is time spent on the
+// stage's own work, blocked is time spent waiting for the next stage to accept
+// its output, which is where back-pressure shows up.
+type stageMetrics struct {
+	name    string
+	workers int
+	queue   func() (depth, capacity int) // input queue of the stage, nil for the first one
+
+	in      atomic.Int64
+	out     atomic.Int64
+	errors  atomic.Int64
+	busy    atomic.Int64 // nanoseconds
+	blocked atomic.Int64 // nanoseconds
+}
+
+func (m *stageMetrics) report() dto.SyncStageMetrics {
+	r := dto.SyncStageMetrics{
+		Stage:          m.name,
+		Workers:        m.workers,
+		In:             m.in.Load(),
+		Out:            m.out.Load(),
+		Errors:         m.errors.Load(),
+		BusySeconds:    time.Duration(m.busy.Load()).Seconds(),
+		BlockedSeconds: time.Duration(m.blocked.Load()).Seconds(),
+	}
+	if m.queue != nil {
+		r.QueueDepth, r.QueueCapacity = m.queue()
+	}
+	return r
+}
+
+// pipelineMetrics is shared with the sync controller so /sync-status can read it
+// while the job runs and after it has finished.
+type pipelineMetrics struct {
+	stages []*stageMetrics
+}
+
+func (p *pipelineMetrics) report() []dto.SyncStageMetrics {
+	out := make([]dto.SyncStageMetrics, 0, len(p.stages))
+	for _, m := range p.stages {
+		out = append(out, m.report())
+	}
+	return out
+}
+
+// pipelinePage carries one ranking page through the pipeline. users and failures
+// are filled by the user workers under mu; pending counts users still in flight.
+type pipelinePage struct {
+	page      int
+	nodes     map[string]RankingNode
+	usernames []string // users to fetch
+	seen      []string // every user on the page
+	skipped   int
+
+	mu       sync.Mutex
+	users    []*models.StageUserDataParams
+	failures []userFetchFailure
+	pending  int
+}
+
+// userTask is one batch query's worth of a page's users
+type userTask struct {
+	page      *pipelinePage
+	usernames []string
+}
+
+// sendStage hands v to the next stage, counting the wait as back-pressure on m
+func sendStage[T any](ctx context.Context, m *stageMetrics, ch chan<- T, v T) error {
+	start := time.Now()
+	defer func() { m.blocked.Add(int64(time.Since(start))) }()
+
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runPipeline sweeps pages opts.StartPage..endPage in three stages connected by
+// bounded queues:
+//
+//	fetch_pages  fetches ranking pages up to opts.Lookahead pages ahead and picks the users to refresh
+//	fetch_users  fetches user profiles on opts.Workers workers, one batch query per task
+//	write        upserts completed pages in page order, opts.BatchSize users at a time, and checkpoints
+//
+// A full queue blocks the stage feeding it, so a slow writer throttles the
+// fetchers instead of piling pages up in memory. It returns the number of users
+// written and, on failure, the first page that was not committed.
+func (s *userService) runPipeline(ctx context.Context, jobID int64, opts SyncOptions, firstPage *ResponseGlobal, endPage int) (int, int, error) {
+	pctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	pages := make(chan *pipelinePage, opts.Lookahead)
+	tasks := make(chan userTask, opts.Workers*2)
+	completed := make(chan *pipelinePage, opts.Lookahead)
+
+	fetchPages := &stageMetrics{name: StageFetchPages, workers: 1}
+	fetchUsers := &stageMetrics{name: StageFetchUsers, workers: opts.Workers, queue: func() (int, int) {
+		return len(tasks), cap(tasks)
+	}}
+	write := &stageMetrics{name: StageWrite, workers: 1, queue: func() (int, int) {
+		return len(completed), cap(completed)
+	}}
+	s.controller.setPipeline(&pipelineMetrics{stages: []*stageMetrics{fetchPages, fetchUsers, write}})
+
+	var wg sync.WaitGroup
+
+	// fetch_pages: the only stage that can stop the sweep early without failing
+	// what is already in flight; pages before failedPage still get written.
+	var fetchErr error
+	failedPage := 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pages)
+
+		for page := opts.StartPage; page <= endPage; page++ {
+			if err := s.controller.waitIfPaused(pctx); err != nil {
+				return
+			}
+			s.controller.setPage(page)
+
+			fetchPages.in.Add(1)
+			start := time.Now()
+			p, err := s.preparePage(pctx, page, firstPage, opts)
+			fetchPages.busy.Add(int64(time.Since(start)))
+			if pctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fetchPages.errors.Add(1)
+				fetchErr, failedPage = err, page
+				return
+			}
+			fetchPages.out.Add(1)
+
+			if err := sendStage(pctx, fetchPages, pages, p); err != nil {
+				return
+			}
+			if page < endPage && opts.Delay > 0 {
+				if err := sleepCtx(pctx, opts.Delay); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// fetch_users: a dispatcher spreads the users of each page over the workers;
+	// the worker that completes a page hands it to the writer. completed is
+	// closed once the dispatcher and every worker are done sending to it.
+	var usersWG sync.WaitGroup
+	usersWG.Add(1)
+	go func() {
+		defer usersWG.Done()
+		defer close(tasks)
+
+		for p := range pages {
+			if len(p.usernames) == 0 {
+				if err := sendStage(pctx, fetchUsers, completed, p); err != nil {
+					return
+				}
+				continue
+			}
+			for _, batch := range s.userBatches(p.usernames) {
+				select {
+				case tasks <- userTask{page: p, usernames: batch}:
+					fetchUsers.in.Add(int64(len(batch)))
+				case <-pctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < opts.Workers; i++ {
+		usersWG.Add(1)
+		go func() {
+			defer usersWG.Done()
+			for task := range tasks {
+				if err := s.controller.waitIfPaused(pctx); err != nil {
+					return
+				}
+
+				start := time.Now()
+				users, failures := s.fetchAndConvertUsers(pctx, s.retry.user, task.usernames)
+				fetchUsers.busy.Add(int64(time.Since(start)))
+				if pctx.Err() != nil {
+					return
+				}
+				fetchUsers.out.Add(int64(len(users)))
+				fetchUsers.errors.Add(int64(len(failures)))
+
+				p := task.page
+				p.mu.Lock()
+				p.users = append(p.users, users...)
+				p.failures = append(p.failures, failures...)
+				p.pending -= len(task.usernames)
+				done := p.pending == 0
+				p.mu.Unlock()
+
+				if done {
+					if err := sendStage(pctx, fetchUsers, completed, p); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		usersWG.Wait()
+		close(completed)
+	}()
+
+	// write
+	var writeErr error
+	writeFailedPage := 0
+	processed := 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		processed, writeFailedPage, writeErr = s.writePages(pctx, jobID, opts, endPage, completed, write)
+		if writeErr != nil && pctx.Err() == nil {
+			cancel(writeErr)
+		}
+	}()
+
+	wg.Wait()
+	usersWG.Wait()
+
+	for _, m := range []*stageMetrics{fetchPages, fetchUsers, write} {
+		r := m.report()
+		s.logger.Info("sync: pipeline stage finished", map[string]any{
+			"job_id":          jobID,
+			"stage":           r.Stage,
+			"in":              r.In,
+			"out":             r.Out,
+			"errors":          r.Errors,
+			"busy_seconds":    r.BusySeconds,
+			"blocked_seconds": r.BlockedSeconds,
+		})
+	}
+
+	switch {
+	case writeErr != nil && ctx.Err() == nil:
+		return processed, writeFailedPage, writeErr
+	case ctx.Err() != nil:
+		return processed, 0, ctx.Err()
+	case fetchErr != nil:
+		return processed, failedPage, fetchErr
+	}
+	return processed, 0, nil
+}
+
+// preparePage fetches a ranking page (the already fetched first page is reused)
+// and, in incremental mode, narrows it down to the users that need a refresh.
+// In ranking mode the page's users are built right away.
+func (s *userService) preparePage(ctx context.Context, page int, firstPage *ResponseGlobal, opts SyncOptions) (*pipelinePage, error) {
+	pageResp := firstPage
+	if page != opts.StartPage || pageResp == nil {
+		var err error
+		pageResp, err = s.fetchRankingPage(ctx, page)
+		if err != nil {
+			return nil, fmt.Errorf("fetch page %d: %w", page, err)
+		}
+	}
+
+	p := &pipelinePage{
+		page:      page,
+		nodes:     rankingNodesByUsername(pageResp),
+		usernames: s.extractUsernamesFromPage(pageResp),
+	}
+	p.seen = p.usernames
+	switch opts.Mode {
+	case SyncModeRanking:
+		// The page is all a ranking sync needs; it skips fetch_users entirely
+		p.users = rankingPageUsers(p.usernames, p.nodes)
+		p.usernames = nil
+	case SyncModeIncremental:
+		stale, err := s.staleUsernames(ctx, p.usernames, p.nodes, opts.MaxAge)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			s.logger.Warnf("sync: page %d: could not check freshness, refreshing every user: %v", page, err)
+		} else {
+			p.skipped = len(p.usernames) - len(stale)
+			p.usernames = stale
+		}
+	}
+	p.pending = len(p.usernames)
+	return p, nil
+}
+
+// writePages reorders completed pages, upserts them in batches and checkpoints
+// the last page of every committed batch, so a resumed job never skips a page.
+func (s *userService) writePages(ctx context.Context, jobID int64, opts SyncOptions, endPage int, completed <-chan *pipelinePage, m *stageMetrics) (int, int, error) {
+	ready := make(map[int]*pipelinePage)
+	next := opts.StartPage
+	var batch []*pipelinePage
+	batchUsers := 0
+	var batchSince time.Time
+	processed := 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		start := time.Now()
+		defer func() { m.busy.Add(int64(time.Since(start))) }()
+
+		written, err := s.writeBatch(ctx, jobID, opts, batch)
+		if err != nil {
+			m.errors.Add(1)
+			return err
+		}
+		m.out.Add(int64(written))
+		processed += written
+		s.logger.Infof("sync: committed pages %d-%d/%d - processed %d users (total: %d)",
+			batch[0].page, batch[len(batch)-1].page, endPage, written, processed)
+		batch, batchUsers = nil, 0
+		return nil
+	}
+
+	ticker := time.NewTicker(writerFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return processed, 0, ctx.Err()
+
+		case <-ticker.C:
+			if len(batch) > 0 && time.Since(batchSince) >= writerFlushInterval {
+				if err := flush(); err != nil {
+					return processed, batch[0].page, err
+				}
+			}
+
+		case p, ok := <-completed:
+			if !ok {
+				if err := flush(); err != nil {
+					return processed, batch[0].page, err
+				}
+				return processed, 0, nil
+			}
+			m.in.Add(1)
+			ready[p.page] = p
+
+			for {
+				q, ok := ready[next]
+				if !ok {
+					break
+				}
+				delete(ready, next)
+				if len(batch) == 0 {
+					batchSince = time.Now()
+				}
+				batch = append(batch, q)
+				batchUsers += len(q.users)
+				next++
+			}
+			if batchUsers >= opts.BatchSize {
+				if err := flush(); err != nil {
+					return processed, batch[0].page, err
+				}
+			}
+		}
+	}
+}
+
+// writeBatch upserts the users of consecutive pages in one statement and
+// checkpoints the last of them. It returns the number of users written.
+func (s *userService) writeBatch(ctx context.Context, jobID int64, opts SyncOptions, batch []*pipelinePage) (int, error) {
+	var users []*models.StageUserDataParams
+	var seen []string
+	failed, skipped := 0, 0
+	for _, p := range batch {
+		for _, user := range p.users {
+			if node, ok := p.nodes[user.Username]; ok {
+				applyRankingNode(user, node)
+			}
+		}
+		users = append(users, p.users...)
+		seen = append(seen, p.seen...)
+		failed += len(p.failures)
+		skipped += p.skipped
+		s.recordFetchFailures(jobID, p.page, opts.DryRun, p.failures)
+	}
+
+	if opts.DryRun {
+		if err := s.recordSeenUsers(ctx, jobID, seen); err != nil {
+			return 0, fmt.Errorf("pages %d-%d: %w", batch[0].page, batch[len(batch)-1].page, err)
+		}
+	}
+
+	if len(users) > 0 {
+		c, cancel := context.WithTimeout(ctx, upsertTimeout)
+		err := s.upsertSyncedUsers(c, jobID, opts, users)
+		cancel()
+		if err != nil {
+			s.logger.Error("failed to sync users", map[string]any{
+				"pages": fmt.Sprintf("%d-%d", batch[0].page, batch[len(batch)-1].page),
+				"count": len(users),
+			})
+			return 0, fmt.Errorf("upsert pages %d-%d: %w", batch[0].page, batch[len(batch)-1].page, err)
+		}
+	}
+
+	s.checkpointSyncJob(jobID, batch[len(batch)-1].page, len(users), failed, skipped)
+	return len(users), nil
+}
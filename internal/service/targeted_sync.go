@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/pp"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// Sync job targets persisted in sync_jobs.target
+const (
+	SyncTargetPages     = "pages"     // sweep the global ranking
+	SyncTargetUsernames = "usernames" // the listed users
+	SyncTargetCountry   = "country"   // every stored user of a country
+	SyncTargetStale     = "stale"     // stored users whose stats were not fetched since a time
+)
+
+// SyncTarget picks a set of users to refresh instead of sweeping the global
+// ranking. At most one of its fields is set; the zero value sweeps pages.
+type SyncTarget struct {
+	Usernames       []string
+	Country         string    // country code, as stored in user_data.country_code
+	NotUpdatedSince time.Time // users whose stats were last fetched before this
+}
+
+func (t SyncTarget) kind() string {
+	switch {
+	case len(t.Usernames) > 0:
+		return SyncTargetUsernames
+	case t.Country != "":
+		return SyncTargetCountry
+	case !t.NotUpdatedSince.IsZero():
+		return SyncTargetStale
+	}
+	return SyncTargetPages
+}
+
+// normalize trims, de-duplicates and sorts the usernames, since a targeted job
+// walks them in order and checkpoints by username, and upper-cases the country.
+func (t *SyncTarget) normalize() {
+	if len(t.Usernames) > 0 {
+		seen := make(map[string]struct{}, len(t.Usernames))
+		usernames := make([]string, 0, len(t.Usernames))
+		for _, username := range t.Usernames {
+			username = strings.TrimSpace(username)
+			if username == "" {
+				continue
+			}
+			if _, ok := seen[username]; !ok {
+				seen[username] = struct{}{}
+				usernames = append(usernames, username)
+			}
+		}
+		sort.Strings(usernames)
+		t.Usernames = usernames
+	}
+	t.Country = strings.ToUpper(strings.TrimSpace(t.Country))
+}
+
+// validateSyncOptions rejects option combinations a sync cannot run with
+func validateSyncOptions(opts *SyncOptions) error {
+	opts.Target.normalize()
+
+	set := 0
+	if len(opts.Target.Usernames) > 0 {
+		set++
+	}
+	if opts.Target.Country != "" {
+		set++
+	}
+	if !opts.Target.NotUpdatedSince.IsZero() {
+		set++
+	}
+	switch {
+	case set > 1:
+		return fmt.Errorf("%w: pick one of usernames, country or not_updated_since", errors_.ErrInvalidSyncOptions)
+	case set == 0:
+		return nil
+	case opts.JobID > 0:
+		return fmt.Errorf("%w: a resumed job keeps its own target", errors_.ErrInvalidSyncOptions)
+	case opts.Distributed:
+		return fmt.Errorf("%w: only page sweeps can be distributed", errors_.ErrInvalidSyncOptions)
+	case opts.Mode != "" && opts.Mode != SyncModeFull:
+		return fmt.Errorf("%w: targeted syncs always refresh every user, mode %s does not apply", errors_.ErrInvalidSyncOptions, opts.Mode)
+	case opts.Target.Country != "" && len(opts.Target.Country) != 2:
+		return fmt.Errorf("%w: country must be a two letter code", errors_.ErrInvalidSyncOptions)
+	}
+	return nil
+}
+
+// targetFromJob restores the target of a persisted job
+func targetFromJob(job *users_storage.SyncJob) SyncTarget {
+	var t SyncTarget
+	switch job.Target {
+	case SyncTargetUsernames:
+		t.Usernames = job.TargetUsernames
+	case SyncTargetCountry:
+		t.Country = job.TargetCountry
+	case SyncTargetStale:
+		t.NotUpdatedSince = job.TargetUpdatedBefore.Time
+	}
+	return t
+}
+
+// runTargetedSync refreshes the users of opts.Target in username order,
+// opts.BatchSize users at a time, starting after the job's checkpoint username.
+// Every batch goes through processUsersConcurrently and UpsertUserData (or the
+// dry-run report) like a ranking page does, and is checkpointed once committed.
+func (s *userService) runTargetedSync(ctx context.Context, job *users_storage.SyncJob, opts SyncOptions) error {
+	pp.Printf("sync: job %d refreshing %s target after %q, workers=%d, batch_size=%d\n",
+		job.ID, job.Target, job.CheckpointUsername, opts.Workers, opts.BatchSize)
+
+	after := job.CheckpointUsername
+	processed := 0
+	for {
+		if err := s.controller.waitIfPaused(ctx); err != nil {
+			break
+		}
+
+		usernames, err := s.nextTargetUsernames(ctx, opts.Target, after, opts.BatchSize)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return s.failSyncJob(job.ID, 0, fmt.Errorf("list %s target users after %q: %w", job.Target, after, err))
+		}
+		if len(usernames) == 0 {
+			break
+		}
+
+		users, failures, err := s.processUsersConcurrently(ctx, usernames, opts.Workers, s.controller.waitIfPaused)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return s.failSyncJob(job.ID, 0, fmt.Errorf("process users after %q: %w", after, err))
+		}
+		s.recordFetchFailures(job.ID, 0, opts.DryRun, failures)
+
+		if len(users) > 0 {
+			c, cancel := context.WithTimeout(ctx, upsertTimeout)
+			err := s.upsertSyncedUsers(c, job.ID, opts, users)
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				s.logger.Error("failed to sync users", map[string]any{"job_id": job.ID, "after": after, "count": len(users)})
+				return s.failSyncJob(job.ID, 0, fmt.Errorf("upsert users after %q: %w", after, err))
+			}
+		}
+
+		after = usernames[len(usernames)-1]
+		s.checkpointTargetedSyncJob(job.ID, after, len(users), len(failures))
+		processed += len(users)
+		s.logger.Infof("sync: job %d committed users up to %q - processed %d users (total: %d)",
+			job.ID, after, len(users), processed)
+	}
+
+	if ctx.Err() != nil {
+		return s.interruptSyncJob(ctx, job.ID, processed)
+	}
+
+	s.finishSyncJob(job.ID, SyncJobFinished)
+	s.logger.Infof("sync: job %d refreshed its %s target. Total processed users: %d", job.ID, job.Target, processed)
+	return nil
+}
+
+// nextTargetUsernames returns up to limit usernames of target after after, in username order
+func (s *userService) nextTargetUsernames(ctx context.Context, target SyncTarget, after string, limit int) ([]string, error) {
+	switch target.kind() {
+	case SyncTargetUsernames:
+		start := sort.Search(len(target.Usernames), func(i int) bool { return target.Usernames[i] > after })
+		return target.Usernames[start:min(start+limit, len(target.Usernames))], nil
+	case SyncTargetCountry:
+		return s.storage.ListUsernamesByCountry(ctx, users_storage.ListUsernamesByCountryParams{
+			Country:       target.Country,
+			AfterUsername: after,
+			LimitCount:    int32(limit),
+		})
+	case SyncTargetStale:
+		return s.storage.ListUsernamesNotFetchedSince(ctx, users_storage.ListUsernamesNotFetchedSinceParams{
+			FetchedBefore: sql.NullTime{Time: target.NotUpdatedSince, Valid: true},
+			AfterUsername: after,
+			LimitCount:    int32(limit),
+		})
+	}
+	return nil, fmt.Errorf("sync target %q has no users to list", target.kind())
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+)
+
+// pqUniqueViolation is the Postgres error code for a unique constraint violation
+const pqUniqueViolation = "23505"
+
+func (s *userService) CreateTrackedGroup(ctx context.Context, name string) (*users_storage.TrackedGroup, error) {
+	group, err := s.storage.CreateTrackedGroup(ctx, name)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return nil, errors_.ErrGroupExists
+		}
+		s.logger.Errorf("CreateTrackedGroup: name=%s err=%v", name, err)
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteTrackedGroup deletes the group. Its members keep the tracked tier until
+// the tiers are recomputed after the next page sweep.
+func (s *userService) DeleteTrackedGroup(ctx context.Context, name string) error {
+	group, err := s.trackedGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.DeleteTrackedGroup(ctx, group.ID); err != nil {
+		s.logger.Errorf("DeleteTrackedGroup: name=%s err=%v", name, err)
+		return err
+	}
+	return nil
+}
+
+func (s *userService) ListTrackedGroups(ctx context.Context) ([]users_storage.ListTrackedGroupsRow, error) {
+	groups, err := s.storage.ListTrackedGroups(ctx)
+	if err != nil {
+		s.logger.Errorf("ListTrackedGroups: err=%v", err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *userService) ListTrackedGroupMembers(ctx context.Context, name string) ([]string, error) {
+	group, err := s.trackedGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.storage.ListTrackedGroupMembers(ctx, group.ID)
+	if err != nil {
+		s.logger.Errorf("ListTrackedGroupMembers: name=%s err=%v", name, err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddTrackedGroupMembers adds usernames to the group and moves them to the
+// tracked tier, even if they are not stored yet.
+func (s *userService) AddTrackedGroupMembers(ctx context.Context, name string, usernames []string) error {
+	group, err := s.trackedGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.AddTrackedGroupMembers(ctx, users_storage.AddTrackedGroupMembersParams{
+		GroupID:   group.ID,
+		Usernames: usernames,
+	}); err != nil {
+		s.logger.Errorf("AddTrackedGroupMembers: name=%s count=%d err=%v", name, len(usernames), err)
+		return err
+	}
+	if err := s.storage.MarkUsersTracked(ctx, usernames); err != nil {
+		s.logger.Errorf("AddTrackedGroupMembers: mark tracked name=%s count=%d err=%v", name, len(usernames), err)
+		return err
+	}
+	return nil
+}
+
+// RemoveTrackedGroupMember removes username from the group. It keeps the
+// tracked tier until the tiers are recomputed after the next page sweep.
+func (s *userService) RemoveTrackedGroupMember(ctx context.Context, name, username string) error {
+	group, err := s.trackedGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.RemoveTrackedGroupMember(ctx, users_storage.RemoveTrackedGroupMemberParams{
+		GroupID:  group.ID,
+		Username: username,
+	}); err != nil {
+		s.logger.Errorf("RemoveTrackedGroupMember: name=%s username=%s err=%v", name, username, err)
+		return err
+	}
+	return nil
+}
+
+func (s *userService) trackedGroup(ctx context.Context, name string) (*users_storage.TrackedGroup, error) {
+	group, err := s.storage.GetTrackedGroupByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors_.ErrGroupNotFound
+	}
+	if err != nil {
+		s.logger.Errorf("GetTrackedGroupByName: name=%s err=%v", name, err)
+		return nil, err
+	}
+	return &group, nil
+}
@@ -6,41 +6,124 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
 	"github.com/ruziba3vich/leetcode_ranking/internal/dto"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
 	"github.com/ruziba3vich/leetcode_ranking/internal/storage"
 	logger "github.com/ruziba3vich/prodonik_lgger"
 )
 
 type userService struct {
-	leetCodeClient *LeetCodeClient
-	storage        users_storage.Querier
-	logger         *logger.Logger
-	dbStorage      *storage.Storage
-	sync           bool
-	syncingPage    int
+	source     LeetCodeSource
+	retry      retryPolicies
+	storage    users_storage.Querier
+	logger     *logger.Logger
+	dbStorage  *storage.Storage
+	controller *syncController
+	cfg        *config.Config
+
+	// serialises dead-letter retry passes (scheduled and admin triggered)
+	retryMu sync.Mutex
+
+	// nudges the lease worker when this replica creates a distributed job
+	leaseWake chan struct{}
+
+	// lifecycle of background sync jobs, independent of any HTTP request
+	syncCtx    context.Context
+	syncCancel context.CancelFunc
+	syncWG     sync.WaitGroup
 }
 
-func NewUserService(storage users_storage.Querier, dbStorage *storage.Storage, leetCodeClient *LeetCodeClient, log *logger.Logger) UserService {
+func NewUserService(cfg *config.Config, storage users_storage.Querier, dbStorage *storage.Storage, source LeetCodeSource, log *logger.Logger) UserService {
+	syncCtx, syncCancel := context.WithCancel(context.Background())
 	return &userService{
-		storage:        storage,
-		dbStorage:      dbStorage,
-		leetCodeClient: leetCodeClient,
-		logger:         log,
+		storage:    storage,
+		dbStorage:  dbStorage,
+		source:     source,
+		retry:      newRetryPolicies(cfg),
+		logger:     log,
+		controller: newSyncController(),
+		cfg:        cfg,
+		syncCtx:    syncCtx,
+		syncCancel: syncCancel,
+		leaseWake:  make(chan struct{}, 1),
+	}
+}
+
+// StartSync runs a sync job in the background under the service's own
+// lifecycle context, so the job outlives the request that started it.
+// The controller is claimed before returning, so two concurrent starts cannot both win.
+func (s *userService) StartSync(opts SyncOptions) error {
+	if err := validateSyncOptions(&opts); err != nil {
+		return err
+	}
+	jobCtx, err := s.controller.begin(s.syncCtx)
+	if err != nil {
+		return err
+	}
+
+	s.syncWG.Add(1)
+	go func() {
+		defer s.syncWG.Done()
+		err := s.runSync(jobCtx, opts)
+		s.controller.finish(err)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Errorf("sync: job failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *userService) PauseSync() error {
+	if err := s.controller.pause(); err != nil {
+		return err
+	}
+	s.setSyncJobStatus(s.controller.snapshot().JobID, SyncJobPaused)
+	s.logger.Info("sync: paused")
+	return nil
+}
+
+func (s *userService) ResumeSync() error {
+	if err := s.controller.resume(); err != nil {
+		return err
 	}
+	s.setSyncJobStatus(s.controller.snapshot().JobID, SyncJobRunning)
+	s.logger.Info("sync: resumed")
+	return nil
 }
 
-func (s *userService) SyncOn() {
-	s.sync = true
+func (s *userService) CancelSync() error {
+	if err := s.controller.cancelJob(); err != nil {
+		return err
+	}
+	s.logger.Info("sync: cancelling")
+	return nil
 }
 
-func (s *userService) SyncOff() {
-	s.sync = false
+// Shutdown cancels the running sync and waits until it has drained or ctx expires.
+// An interrupted job keeps its checkpoint and is resumed on the next start.
+func (s *userService) Shutdown(ctx context.Context) error {
+	s.syncCancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.syncWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logger.Info("sync: background jobs drained")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for sync to drain: %w", ctx.Err())
+	}
 }
 
 func (s *userService) CreateUser(ctx context.Context, req *dto.CreateUserRequest) (*users_storage.UserDatum, error) {
-	data, err := s.fetchAndConvertUser(req.Username)
+	data, err := s.fetchAndConvertUser(ctx, s.retry.interactive, req.Username)
 	if err != nil {
 		s.logger.Error("could not fetch user", map[string]any{"error": err.Error(), "username": req.Username})
 		return nil, err
@@ -70,6 +153,13 @@ func (s *userService) CreateUser(ctx context.Context, req *dto.CreateUserRequest
 		},
 		TotalProblemsSolved: int32(data.TotalProblemsSolved),
 		TotalSubmissions:    int32(data.TotalSubmissions),
+		EasySolved:          data.EasySolved,
+		MediumSolved:        data.MediumSolved,
+		HardSolved:          data.HardSolved,
+		EasySubmissions:     data.EasySubmissions,
+		MediumSubmissions:   data.MediumSubmissions,
+		HardSubmissions:     data.HardSubmissions,
+		AllSubmissions:      data.AllSubmissions,
 	}
 	if strings.TrimSpace(arg.Username) == "" {
 		return nil, fmt.Errorf("username is required")
@@ -81,6 +171,11 @@ func (s *userService) CreateUser(ctx context.Context, req *dto.CreateUserRequest
 		return nil, err
 	}
 	s.logger.Infof("CreateUser: username=%s id=%d", u.Username, u.ID)
+
+	// users added by hand are refreshed in the manual tier from now on
+	if err := s.storage.MarkUserManual(ctx, u.Username); err != nil {
+		s.logger.Errorf("CreateUser: username=%s failed to set manual refresh tier: %v", u.Username, err)
+	}
 	return &u, nil
 }
 
@@ -131,6 +226,32 @@ func (s *userService) GetUsersByCountry(ctx context.Context, arg *users_storage.
 	}, nil
 }
 
+func (s *userService) GetUserHistory(ctx context.Context, req *dto.GetUserHistoryRequest) (*dto.GetUserHistoryResponse, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+
+	points, err := s.storage.GetUserStatsHistory(ctx, users_storage.GetUserStatsHistoryParams{
+		Granularity: req.Granularity,
+		Username:    username,
+		FromTime:    req.From,
+		ToTime:      req.To,
+	})
+	if err != nil {
+		s.logger.Errorf("GetUserHistory: username=%s err=%v", username, err)
+		return nil, err
+	}
+	s.logger.Infof("GetUserHistory: username=%s granularity=%s points=%d", username, req.Granularity, len(points))
+	return &dto.GetUserHistoryResponse{
+		Username:    username,
+		Granularity: req.Granularity,
+		From:        req.From,
+		To:          req.To,
+		Points:      points,
+	}, nil
+}
+
 func (s *userService) UpdateUserByUsername(ctx context.Context, arg *users_storage.UpdateUserByUsernameParams) (*users_storage.UserDatum, error) {
 	if strings.TrimSpace(arg.Username) == "" {
 		return nil, fmt.Errorf("username is required")
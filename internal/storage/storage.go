@@ -4,26 +4,46 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
-	"github.com/k0kubun/pp"
 	"github.com/lib/pq"
 	"github.com/ruziba3vich/leetcode_ranking/internal/models"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
 )
 
 const (
-	userDataTable        = "user_data"
-	stagingUserDataTable = "staging_user_data"
+	userDataTable          = "user_data"
+	stagingUserDataTable   = "staging_user_data" // column template of stagingBatchTable, never written
+	stagingBatchTable      = "staging_user_data_batch"
+	userStatsSnapshotTable = "user_stats_snapshots"
+	userChangeEventsTable  = "user_change_events"
+	eventOutboxTable       = "event_outbox"
 )
 
 type Storage struct {
 	db *sql.DB
+	// enqueueEvents queues change events in event_outbox. It is off while no
+	// outbox sink is configured, so undeliverable events do not pile up there.
+	enqueueEvents bool
 }
 
-func NewStorage(db *sql.DB) *Storage {
-	return &Storage{db: db}
+func NewStorage(db *sql.DB, cfg *config.Config) *Storage {
+	return &Storage{db: db, enqueueEvents: cfg.Outbox.Enabled()}
 }
 
-// UpsertUserData copies all records into staging table, then merges into actual table with upsert
+// UpsertUserData copies all records into a staging table of its own transaction,
+// then merges into actual table with upsert and appends a stats snapshot for
+// every merged user.
+// Contest fields are only overwritten when the incoming record carries them, so a
+// profile-only refresh keeps the rating and rank taken from the last ranking page.
+// A merged user was just seen on LeetCode, so a missing, deleted or renamed
+// account becomes active again.
+// Only users whose values differ are rewritten, and what changed is recorded in
+// user_change_events and, while an outbox sink is configured, queued in
+// event_outbox for delivery; unchanged users just get stats_fetched_at and
+// last_seen_at bumped, which leaves their updated_at alone.
 func (s *Storage) UpsertUserData(ctx context.Context, records []*models.StageUserDataParams) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -31,14 +51,425 @@ func (s *Storage) UpsertUserData(ctx context.Context, records []*models.StageUse
 	}
 	defer tx.Rollback()
 
-	// Clean staging table
-	if _, err := tx.ExecContext(ctx, fmt.Sprintf("TRUNCATE %s;", stagingUserDataTable)); err != nil {
-		return fmt.Errorf("truncate staging: %w", err)
+	if err := stageRecords(ctx, tx, records); err != nil {
+		return err
+	}
+
+	// Merge into actual table with upsert
+	upsertQuery := fmt.Sprintf(`
+		INSERT INTO %s (
+			username,
+			user_slug,
+			user_avatar,
+			country_code,
+			country_name,
+			real_name,
+			typename,
+			total_problems_solved,
+			total_submissions,
+			contest_rating,
+			global_ranking,
+			contest_rankings,
+			data_region,
+			easy_solved,
+			medium_solved,
+			hard_solved,
+			easy_submissions,
+			medium_submissions,
+			hard_submissions,
+			all_submissions
+		)
+		SELECT
+			username,
+			user_slug,
+			user_avatar,
+			country_code,
+			country_name,
+			real_name,
+			typename,
+			total_problems_solved,
+			total_submissions,
+			contest_rating,
+			global_ranking,
+			contest_rankings,
+			data_region,
+			easy_solved,
+			medium_solved,
+			hard_solved,
+			easy_submissions,
+			medium_submissions,
+			hard_submissions,
+			all_submissions
+		FROM %s
+		ORDER BY username
+		ON CONFLICT (username) DO UPDATE SET
+			user_slug = EXCLUDED.user_slug,
+			user_avatar = EXCLUDED.user_avatar,
+			country_code = EXCLUDED.country_code,
+			country_name = EXCLUDED.country_name,
+			real_name = EXCLUDED.real_name,
+			typename = EXCLUDED.typename,
+			total_problems_solved = EXCLUDED.total_problems_solved,
+			total_submissions = EXCLUDED.total_submissions,
+			contest_rating = COALESCE(EXCLUDED.contest_rating, %[1]s.contest_rating),
+			global_ranking = COALESCE(EXCLUDED.global_ranking, %[1]s.global_ranking),
+			contest_rankings = COALESCE(EXCLUDED.contest_rankings, %[1]s.contest_rankings),
+			data_region = COALESCE(EXCLUDED.data_region, %[1]s.data_region),
+			easy_solved = EXCLUDED.easy_solved,
+			medium_solved = EXCLUDED.medium_solved,
+			hard_solved = EXCLUDED.hard_solved,
+			easy_submissions = EXCLUDED.easy_submissions,
+			medium_submissions = EXCLUDED.medium_submissions,
+			hard_submissions = EXCLUDED.hard_submissions,
+			all_submissions = EXCLUDED.all_submissions,
+			stats_fetched_at = NOW(),
+			account_status = 'active',
+			status_changed_at = CASE WHEN %[1]s.account_status = 'active' THEN %[1]s.status_changed_at ELSE NOW() END,
+			last_seen_at = NOW(),
+			missing_since = NULL,
+			miss_count = 0,
+			renamed_to = NULL,
+			last_missed_at = NULL
+		WHERE (
+			%[1]s.user_slug, %[1]s.user_avatar, %[1]s.country_code, %[1]s.country_name,
+			%[1]s.real_name, %[1]s.typename,
+			%[1]s.total_problems_solved, %[1]s.total_submissions,
+			%[1]s.contest_rating, %[1]s.global_ranking, %[1]s.contest_rankings, %[1]s.data_region,
+			%[1]s.easy_solved, %[1]s.medium_solved, %[1]s.hard_solved,
+			%[1]s.easy_submissions, %[1]s.medium_submissions, %[1]s.hard_submissions,
+			%[1]s.all_submissions, %[1]s.account_status
+		) IS DISTINCT FROM (
+			EXCLUDED.user_slug, EXCLUDED.user_avatar, EXCLUDED.country_code, EXCLUDED.country_name,
+			EXCLUDED.real_name, EXCLUDED.typename,
+			EXCLUDED.total_problems_solved, EXCLUDED.total_submissions,
+			COALESCE(EXCLUDED.contest_rating, %[1]s.contest_rating),
+			COALESCE(EXCLUDED.global_ranking, %[1]s.global_ranking),
+			COALESCE(EXCLUDED.contest_rankings, %[1]s.contest_rankings),
+			COALESCE(EXCLUDED.data_region, %[1]s.data_region),
+			EXCLUDED.easy_solved, EXCLUDED.medium_solved, EXCLUDED.hard_solved,
+			EXCLUDED.easy_submissions, EXCLUDED.medium_submissions, EXCLUDED.hard_submissions,
+			EXCLUDED.all_submissions, 'active'
+		)
+	`, userDataTable, stagingBatchTable)
+
+	if err := s.mergeStaged(ctx, tx, upsertQuery, "stats_fetched_at", "last_seen_at"); err != nil {
+		return fmt.Errorf("merge into actual table: %w", err)
+	}
+
+	if err := insertSnapshots(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// UpsertRankingData merges records built from ranking pages alone: profile,
+// country, contest rating and rank. Solved and submission counts of stored users
+// are left as they are, and so is stats_fetched_at, so the users still count as
+// due for a full refresh. New users are inserted with zero counts and no
+// stats_fetched_at. Like UpsertUserData, it reactivates the accounts it merges
+// and only rewrites, and records changes of, users whose values differ.
+func (s *Storage) UpsertRankingData(ctx context.Context, records []*models.StageUserDataParams) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := stageRecords(ctx, tx, records); err != nil {
+		return err
+	}
+
+	upsertQuery := fmt.Sprintf(`
+		INSERT INTO %s (
+			username,
+			user_slug,
+			user_avatar,
+			country_code,
+			country_name,
+			real_name,
+			typename,
+			contest_rating,
+			global_ranking,
+			contest_rankings,
+			data_region,
+			stats_fetched_at
+		)
+		SELECT
+			username,
+			user_slug,
+			user_avatar,
+			country_code,
+			country_name,
+			real_name,
+			typename,
+			contest_rating,
+			global_ranking,
+			contest_rankings,
+			data_region,
+			NULL
+		FROM %s
+		ORDER BY username
+		ON CONFLICT (username) DO UPDATE SET
+			user_slug = EXCLUDED.user_slug,
+			user_avatar = EXCLUDED.user_avatar,
+			country_code = EXCLUDED.country_code,
+			country_name = EXCLUDED.country_name,
+			real_name = EXCLUDED.real_name,
+			typename = EXCLUDED.typename,
+			contest_rating = COALESCE(EXCLUDED.contest_rating, %[1]s.contest_rating),
+			global_ranking = COALESCE(EXCLUDED.global_ranking, %[1]s.global_ranking),
+			contest_rankings = COALESCE(EXCLUDED.contest_rankings, %[1]s.contest_rankings),
+			data_region = COALESCE(EXCLUDED.data_region, %[1]s.data_region),
+			account_status = 'active',
+			status_changed_at = CASE WHEN %[1]s.account_status = 'active' THEN %[1]s.status_changed_at ELSE NOW() END,
+			last_seen_at = NOW(),
+			missing_since = NULL,
+			miss_count = 0,
+			renamed_to = NULL,
+			last_missed_at = NULL
+		WHERE (
+			%[1]s.user_slug, %[1]s.user_avatar, %[1]s.country_code, %[1]s.country_name,
+			%[1]s.real_name, %[1]s.typename,
+			%[1]s.contest_rating, %[1]s.global_ranking, %[1]s.contest_rankings, %[1]s.data_region,
+			%[1]s.account_status
+		) IS DISTINCT FROM (
+			EXCLUDED.user_slug, EXCLUDED.user_avatar, EXCLUDED.country_code, EXCLUDED.country_name,
+			EXCLUDED.real_name, EXCLUDED.typename,
+			COALESCE(EXCLUDED.contest_rating, %[1]s.contest_rating),
+			COALESCE(EXCLUDED.global_ranking, %[1]s.global_ranking),
+			COALESCE(EXCLUDED.contest_rankings, %[1]s.contest_rankings),
+			COALESCE(EXCLUDED.data_region, %[1]s.data_region),
+			'active'
+		)
+	`, userDataTable, stagingBatchTable)
+
+	if err := s.mergeStaged(ctx, tx, upsertQuery, "last_seen_at"); err != nil {
+		return fmt.Errorf("merge ranking data: %w", err)
+	}
+
+	if err := insertSnapshots(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// mergedUser is a user the upsert inserted or rewrote, with the values change
+// events are made of before (previous*) and after the merge
+type mergedUser struct {
+	username        string
+	isNew           bool
+	previousFetched bool // stats were fetched before, so previousSolved is real
+	fetched         bool
+	previousSolved  int32
+	solved          int32
+	previousRating  sql.NullFloat64
+	rating          sql.NullFloat64
+	previousCountry string
+	country         string
+}
+
+// mergeStaged runs upsertQuery, an INSERT ... ON CONFLICT DO UPDATE ... WHERE
+// of the staged users that skips unchanged rows, records the change events of
+// the rows it wrote and then sets the touched timestamp columns of the staged
+// users it skipped, so they still count as seen or fetched.
+func (s *Storage) mergeStaged(ctx context.Context, tx *sql.Tx, upsertQuery string, touched ...string) error {
+	// previous reads the statement's snapshot, so it holds the values from before merged ran
+	mergeQuery := fmt.Sprintf(`
+		WITH previous AS (
+			SELECT u.username, u.stats_fetched_at, u.total_problems_solved, u.contest_rating, u.country_code
+			FROM %s u
+			JOIN %s s ON s.username = u.username
+		),
+		merged AS (
+			%s
+			RETURNING username, stats_fetched_at, total_problems_solved, contest_rating, country_code
+		)
+		SELECT
+			m.username,
+			p.username IS NULL,
+			p.stats_fetched_at IS NOT NULL,
+			m.stats_fetched_at IS NOT NULL,
+			COALESCE(p.total_problems_solved, 0),
+			m.total_problems_solved,
+			p.contest_rating,
+			m.contest_rating,
+			TRIM(COALESCE(p.country_code, '')),
+			TRIM(COALESCE(m.country_code, ''))
+		FROM merged m
+		LEFT JOIN previous p ON p.username = m.username
+		ORDER BY m.username;
+	`, userDataTable, stagingBatchTable, upsertQuery)
+
+	rows, err := tx.QueryContext(ctx, mergeQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var merged []mergedUser
+	for rows.Next() {
+		var m mergedUser
+		if err := rows.Scan(
+			&m.username,
+			&m.isNew,
+			&m.previousFetched,
+			&m.fetched,
+			&m.previousSolved,
+			&m.solved,
+			&m.previousRating,
+			&m.rating,
+			&m.previousCountry,
+			&m.country,
+		); err != nil {
+			return fmt.Errorf("scan merged user: %w", err)
+		}
+		merged = append(merged, m)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := s.insertChangeEvents(ctx, tx, changeEvents(merged)); err != nil {
+		return err
+	}
+
+	// Rows the upsert wrote already carry NOW(), the transaction's start time
+	sets := make([]string, len(touched))
+	stale := make([]string, len(touched))
+	for i, col := range touched {
+		sets[i] = fmt.Sprintf("%s = NOW()", col)
+		stale[i] = fmt.Sprintf("u.%s IS DISTINCT FROM NOW()", col)
+	}
+	touchQuery := fmt.Sprintf(`
+		UPDATE %s u
+		SET %s
+		FROM %s s
+		WHERE s.username = u.username
+		  AND (%s);
+	`, userDataTable, strings.Join(sets, ", "), stagingBatchTable, strings.Join(stale, " OR "))
+	if _, err := tx.ExecContext(ctx, touchQuery); err != nil {
+		return fmt.Errorf("touch unchanged users: %w", err)
+	}
+	return nil
+}
+
+// changeEvents lists what the merge changed: a new user, or for a stored one
+// more problems solved, a different contest rating or a different country.
+// Solved counts only mean something once stats were fetched: a user first
+// stored from a ranking page has zeros, so its new_user event carries no count
+// and its first full fetch is not reported as solved_increased.
+func changeEvents(merged []mergedUser) []models.UserChangeEvent {
+	var events []models.UserChangeEvent
+	for _, m := range merged {
+		if m.isNew {
+			event := models.UserChangeEvent{
+				Username: m.username,
+				Kind:     models.ChangeEventNewUser,
+			}
+			if m.fetched {
+				event.NewValue = strconv.Itoa(int(m.solved))
+			}
+			events = append(events, event)
+			continue
+		}
+		if m.previousFetched && m.fetched && m.solved > m.previousSolved {
+			events = append(events, models.UserChangeEvent{
+				Username: m.username,
+				Kind:     models.ChangeEventSolvedIncreased,
+				OldValue: strconv.Itoa(int(m.previousSolved)),
+				NewValue: strconv.Itoa(int(m.solved)),
+			})
+		}
+		if m.rating != m.previousRating {
+			events = append(events, models.UserChangeEvent{
+				Username: m.username,
+				Kind:     models.ChangeEventRatingChanged,
+				OldValue: models.FormatRating(m.previousRating),
+				NewValue: models.FormatRating(m.rating),
+			})
+		}
+		if m.country != m.previousCountry {
+			events = append(events, models.UserChangeEvent{
+				Username: m.username,
+				Kind:     models.ChangeEventCountryChanged,
+				OldValue: m.previousCountry,
+				NewValue: m.country,
+			})
+		}
+	}
+	return events
+}
+
+// insertChangeEvents records events and, when enqueueEvents is set, queues each
+// one in the outbox within tx, so an event is delivered if and only if the
+// change it describes was committed
+func (s *Storage) insertChangeEvents(ctx context.Context, tx *sql.Tx, events []models.UserChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	usernames := make([]string, len(events))
+	kinds := make([]string, len(events))
+	oldValues := make([]string, len(events))
+	newValues := make([]string, len(events))
+	for i, e := range events {
+		usernames[i], kinds[i], oldValues[i], newValues[i] = e.Username, e.Kind, e.OldValue, e.NewValue
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (username, kind, old_value, new_value)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[]);
+	`, userChangeEventsTable)
+	if s.enqueueEvents {
+		query = fmt.Sprintf(`
+			WITH inserted AS (
+				INSERT INTO %s (username, kind, old_value, new_value)
+				SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[])
+				RETURNING id, username
+			)
+			INSERT INTO %s (event_id, username)
+			SELECT id, username FROM inserted ORDER BY id;
+		`, userChangeEventsTable, eventOutboxTable)
+	}
+
+	if _, err := tx.ExecContext(ctx, query,
+		pq.Array(usernames),
+		pq.Array(kinds),
+		pq.Array(oldValues),
+		pq.Array(newValues),
+	); err != nil {
+		return fmt.Errorf("insert %d change events: %w", len(events), err)
+	}
+	return nil
+}
+
+// stageRecords COPYs records into a temp table that only tx sees and that is
+// dropped when tx ends, so concurrent upserts (sync workers, CreateUser, other
+// replicas) never share or wait on staging rows. A username that appears more
+// than once is staged once, with its last record.
+func stageRecords(ctx context.Context, tx *sql.Tx, records []*models.StageUserDataParams) error {
+	records = dedupeRecords(records)
+
+	// No UNIQUE here, unlike the template: records are already de-duplicated
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP;",
+		stagingBatchTable, stagingUserDataTable,
+	)); err != nil {
+		return fmt.Errorf("create staging table: %w", err)
 	}
 
 	// Prepare COPY INTO staging
 	stmt, err := tx.Prepare(pq.CopyIn(
-		stagingUserDataTable,
+		stagingBatchTable,
 		"username",
 		"user_slug",
 		"user_avatar",
@@ -48,6 +479,17 @@ func (s *Storage) UpsertUserData(ctx context.Context, records []*models.StageUse
 		"typename",
 		"total_problems_solved",
 		"total_submissions",
+		"contest_rating",
+		"global_ranking",
+		"contest_rankings",
+		"data_region",
+		"easy_solved",
+		"medium_solved",
+		"hard_solved",
+		"easy_submissions",
+		"medium_submissions",
+		"hard_submissions",
+		"all_submissions",
 	))
 	if err != nil {
 		return fmt.Errorf("prepare copyin: %w", err)
@@ -64,62 +506,81 @@ func (s *Storage) UpsertUserData(ctx context.Context, records []*models.StageUse
 			r.Typename,
 			r.TotalProblemsSolved,
 			r.TotalSubmissions,
+			r.ContestRating,
+			r.GlobalRanking,
+			r.ContestRankings,
+			r.DataRegion,
+			r.EasySolved,
+			r.MediumSolved,
+			r.HardSolved,
+			r.EasySubmissions,
+			r.MediumSubmissions,
+			r.HardSubmissions,
+			r.AllSubmissions,
 		); err != nil {
 			return fmt.Errorf("copyin exec: %w", err)
 		}
 	}
 
 	if _, err := stmt.Exec(); err != nil {
-		pp.Println(err.Error())
 		return fmt.Errorf("finalize copyin: %w", err)
 	}
 	if err := stmt.Close(); err != nil {
 		return fmt.Errorf("close stmt: %w", err)
 	}
+	return nil
+}
 
-	// Merge into actual table with upsert
-	mergeQuery := fmt.Sprintf(`
+// dedupeRecords keeps the last record of every username, ordered by username.
+// Merging in one order makes concurrent upserts of overlapping users lock the
+// user_data rows in the same order, so they wait for each other instead of
+// deadlocking.
+func dedupeRecords(records []*models.StageUserDataParams) []*models.StageUserDataParams {
+	byUsername := make(map[string]*models.StageUserDataParams, len(records))
+	for _, r := range records {
+		byUsername[r.Username] = r
+	}
+	deduped := make([]*models.StageUserDataParams, 0, len(byUsername))
+	for _, r := range byUsername {
+		deduped = append(deduped, r)
+	}
+	sort.Slice(deduped, func(i, j int) bool { return deduped[i].Username < deduped[j].Username })
+	return deduped
+}
+
+// insertSnapshots records a snapshot of the merged values of every staged user
+// so progress can be charted over time. Users whose stats were never fetched
+// would only chart zeros and are left out.
+func insertSnapshots(ctx context.Context, tx *sql.Tx) error {
+	snapshotQuery := fmt.Sprintf(`
 		INSERT INTO %s (
 			username,
-			user_slug,
-			user_avatar,
-			country_code,
-			country_name,
-			real_name,
-			typename,
 			total_problems_solved,
-			total_submissions
+			total_submissions,
+			easy_solved,
+			medium_solved,
+			hard_solved,
+			all_submissions,
+			contest_rating,
+			global_ranking
 		)
 		SELECT
-			username,
-			user_slug,
-			user_avatar,
-			country_code,
-			country_name,
-			real_name,
-			typename,
-			total_problems_solved,
-			total_submissions
-		FROM %s
-		ON CONFLICT (username) DO UPDATE SET
-			user_slug = EXCLUDED.user_slug,
-			user_avatar = EXCLUDED.user_avatar,
-			country_code = EXCLUDED.country_code,
-			country_name = EXCLUDED.country_name,
-			real_name = EXCLUDED.real_name,
-			typename = EXCLUDED.typename,
-			total_problems_solved = EXCLUDED.total_problems_solved,
-			total_submissions = EXCLUDED.total_submissions;
-	`, userDataTable, stagingUserDataTable)
+			u.username,
+			u.total_problems_solved,
+			u.total_submissions,
+			u.easy_solved,
+			u.medium_solved,
+			u.hard_solved,
+			u.all_submissions,
+			u.contest_rating,
+			u.global_ranking
+		FROM %s u
+		JOIN %s s ON s.username = u.username
+		WHERE u.stats_fetched_at IS NOT NULL;
+	`, userStatsSnapshotTable, userDataTable, stagingBatchTable)
 
-	if _, err := tx.ExecContext(ctx, mergeQuery); err != nil {
-		pp.Println(err.Error())
-		return fmt.Errorf("merge into actual table: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		pp.Println(err.Error())
-		return fmt.Errorf("commit tx: %w", err)
+	if _, err := tx.ExecContext(ctx, snapshotQuery); err != nil {
+		return fmt.Errorf("insert snapshots: %w", err)
 	}
 	return nil
 }
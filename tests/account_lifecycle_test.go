@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	"github.com/ruziba3vich/leetcode_ranking/internal/service"
+	logger "github.com/ruziba3vich/prodonik_lgger"
+)
+
+// accountStore is an in-memory stand-in for the dead-letter and account
+// lifecycle queries, following what their SQL does
+type accountStore struct {
+	users_storage.Querier
+	failed  map[string]*users_storage.FailedUserFetch
+	status  map[string]string // user_data.account_status
+	misses  map[string]int32
+	missed  map[string]time.Time // user_data.last_missed_at
+	fetches map[string]int       // RecordFailedUserFetch calls per user
+	users   map[string]users_storage.UserDatum
+}
+
+func newAccountStore() *accountStore {
+	return &accountStore{
+		failed:  make(map[string]*users_storage.FailedUserFetch),
+		status:  make(map[string]string),
+		misses:  make(map[string]int32),
+		missed:  make(map[string]time.Time),
+		fetches: make(map[string]int),
+		users:   make(map[string]users_storage.UserDatum),
+	}
+}
+
+// addUser stores an active user with the given profile, created created ago
+func (s *accountStore) addUser(username, realName, country, avatar string, created time.Duration) {
+	s.status[username] = service.AccountActive
+	s.users[username] = users_storage.UserDatum{
+		Username:    username,
+		RealName:    sql.NullString{String: realName, Valid: realName != ""},
+		CountryCode: sql.NullString{String: country, Valid: country != ""},
+		UserAvatar:  sql.NullString{String: avatar, Valid: avatar != ""},
+		CreatedAt:   time.Now().Add(-created),
+	}
+}
+
+func (s *accountStore) ListFailedUserFetches(ctx context.Context, arg users_storage.ListFailedUserFetchesParams) ([]users_storage.FailedUserFetch, error) {
+	var out []users_storage.FailedUserFetch
+	for _, f := range s.failed {
+		if f.Status == arg.Status {
+			out = append(out, *f)
+		}
+	}
+	return out, nil
+}
+
+// missedRecently reports whether username's last counted miss is younger than
+// intervalSeconds
+func (s *accountStore) missedRecently(username string, intervalSeconds int32) bool {
+	last, ok := s.missed[username]
+	return ok && time.Since(last) < time.Duration(intervalSeconds)*time.Second
+}
+
+// passTime moves every recorded miss d into the past
+func (s *accountStore) passTime(d time.Duration) {
+	for username, last := range s.missed {
+		s.missed[username] = last.Add(-d)
+	}
+}
+
+func (s *accountStore) ListMissingUsersToRecheck(ctx context.Context, arg users_storage.ListMissingUsersToRecheckParams) ([]string, error) {
+	var out []string
+	for username, f := range s.failed {
+		if f.Status == service.FailedUserPermanent && f.ErrorClass == "user_not_found" && s.status[username] == service.AccountMissing &&
+			!s.missedRecently(username, arg.MissIntervalSeconds) {
+			out = append(out, username)
+		}
+	}
+	return out, nil
+}
+
+func (s *accountStore) RecordFailedUserFetch(ctx context.Context, arg users_storage.RecordFailedUserFetchParams) (users_storage.FailedUserFetch, error) {
+	s.fetches[arg.Username]++
+	f, ok := s.failed[arg.Username]
+	if !ok {
+		f = &users_storage.FailedUserFetch{Username: arg.Username}
+		s.failed[arg.Username] = f
+	}
+	f.ErrorClass = arg.ErrorClass
+	f.Attempts++
+	f.Status = service.FailedUserPending
+	if f.Attempts >= arg.MaxAttempts {
+		f.Status = service.FailedUserPermanent
+	}
+	return *f, nil
+}
+
+func (s *accountStore) MarkUsersMissing(ctx context.Context, arg users_storage.MarkUsersMissingParams) ([]users_storage.MarkUsersMissingRow, error) {
+	var out []users_storage.MarkUsersMissingRow
+	for _, username := range arg.Usernames {
+		if st := s.status[username]; st != service.AccountActive && st != service.AccountMissing {
+			continue
+		}
+		if s.missedRecently(username, arg.MissIntervalSeconds) {
+			continue
+		}
+		s.misses[username]++
+		s.missed[username] = time.Now()
+		s.status[username] = service.AccountMissing
+		if s.misses[username] >= arg.MissesToDelete {
+			s.status[username] = service.AccountDeleted
+		}
+		out = append(out, users_storage.MarkUsersMissingRow{
+			Username:      username,
+			AccountStatus: s.status[username],
+			MissCount:     s.misses[username],
+		})
+	}
+	return out, nil
+}
+
+func (s *accountStore) LinkRenamedUsers(ctx context.Context, usernames []string) ([]users_storage.LinkRenamedUsersRow, error) {
+	var out []users_storage.LinkRenamedUsersRow
+	for _, username := range usernames {
+		if st := s.status[username]; st != service.AccountMissing && st != service.AccountDeleted {
+			continue
+		}
+		o := s.users[username]
+		var matches []string
+		for _, n := range s.users {
+			if n.Username == o.Username || s.status[n.Username] != service.AccountActive || !n.CreatedAt.After(o.CreatedAt) {
+				continue
+			}
+			sameAvatar := o.UserAvatar.String != "" && !strings.Contains(strings.ToLower(o.UserAvatar.String), "default_avatar") &&
+				n.UserAvatar == o.UserAvatar
+			sameProfile := o.RealName.String != "" && n.RealName == o.RealName &&
+				n.CountryCode == o.CountryCode && n.UserAvatar == o.UserAvatar
+			if sameAvatar || sameProfile {
+				matches = append(matches, n.Username)
+			}
+		}
+		if len(matches) != 1 {
+			continue
+		}
+		s.status[username] = service.AccountRenamed
+		o.RenamedTo = sql.NullString{String: matches[0], Valid: true}
+		s.users[username] = o
+		out = append(out, users_storage.LinkRenamedUsersRow{Username: username, RenamedTo: o.RenamedTo})
+	}
+	return out, nil
+}
+
+// newAccountService runs the service against store and a fake LeetCode that
+// knows none of the users
+func newAccountService(t *testing.T, cfg *config.Config, store *accountStore) service.UserService {
+	t.Helper()
+	log, err := logger.NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	return service.NewUserService(cfg, store, nil, newFakeSource(t), log)
+}
+
+func TestRetryFailedUsers_NotFoundIsPermanentAtOnce(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Load()
+	cfg.FailedUsers.MaxAttempts = 5
+	cfg.Accounts.MissesToDelete = 3
+
+	store := newAccountStore()
+	store.status["ghost"] = service.AccountActive
+	store.failed["ghost"] = &users_storage.FailedUserFetch{Username: "ghost", ErrorClass: "transient", Attempts: 1, Status: service.FailedUserPending}
+	srv := newAccountService(t, cfg, store)
+
+	resp, err := srv.RetryFailedUsers(ctx, 0)
+	if err != nil {
+		t.Fatalf("RetryFailedUsers: %v", err)
+	}
+	if resp.Permanent != 1 || resp.StillPending != 0 {
+		t.Errorf("permanent=%d still_pending=%d, want 1 and 0", resp.Permanent, resp.StillPending)
+	}
+	if got := store.failed["ghost"].Status; got != service.FailedUserPermanent {
+		t.Errorf("dead-letter status = %s after the first not-found, want %s", got, service.FailedUserPermanent)
+	}
+	if store.misses["ghost"] != 1 || store.status["ghost"] != service.AccountMissing {
+		t.Errorf("account = %s with %d misses, want missing with 1", store.status["ghost"], store.misses["ghost"])
+	}
+}
+
+func TestRetryFailedUsers_MissingAccountReachesDeletion(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Load()
+	// fewer attempts than misses: the dead letter alone would give up first
+	cfg.FailedUsers.MaxAttempts = 2
+	cfg.Accounts.MissesToDelete = 3
+
+	store := newAccountStore()
+	store.status["ghost"] = service.AccountActive
+	store.failed["ghost"] = &users_storage.FailedUserFetch{Username: "ghost", ErrorClass: "transient", Attempts: 1, Status: service.FailedUserPending}
+	srv := newAccountService(t, cfg, store)
+
+	for pass := 1; pass <= cfg.Accounts.MissesToDelete; pass++ {
+		if _, err := srv.RetryFailedUsers(ctx, 0); err != nil {
+			t.Fatalf("pass %d: RetryFailedUsers: %v", pass, err)
+		}
+		store.passTime(cfg.Accounts.MissInterval)
+	}
+	if got := store.status["ghost"]; got != service.AccountDeleted {
+		t.Fatalf("account = %s after %d passes, want %s", got, cfg.Accounts.MissesToDelete, service.AccountDeleted)
+	}
+
+	// a confirmed deletion is not re-checked any more
+	resp, err := srv.RetryFailedUsers(ctx, 0)
+	if err != nil {
+		t.Fatalf("RetryFailedUsers: %v", err)
+	}
+	if resp.Attempted != 0 || store.fetches["ghost"] != cfg.Accounts.MissesToDelete {
+		t.Errorf("deleted account was fetched again: attempted=%d, recorded %d failures", resp.Attempted, store.fetches["ghost"])
+	}
+}
+
+func TestRetryFailedUsers_MissesAreCountedOncePerInterval(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Load()
+	cfg.FailedUsers.MaxAttempts = 5
+	cfg.Accounts.MissesToDelete = 2
+	cfg.Accounts.MissInterval = 24 * time.Hour
+
+	store := newAccountStore()
+	store.status["ghost"] = service.AccountActive
+	store.failed["ghost"] = &users_storage.FailedUserFetch{Username: "ghost", ErrorClass: "transient", Attempts: 1, Status: service.FailedUserPending}
+	srv := newAccountService(t, cfg, store)
+
+	if _, err := srv.RetryFailedUsers(ctx, 0); err != nil {
+		t.Fatalf("RetryFailedUsers: %v", err)
+	}
+	// later passes of the same day neither re-check nor count the account
+	for pass := 2; pass <= 4; pass++ {
+		resp, err := srv.RetryFailedUsers(ctx, 0)
+		if err != nil {
+			t.Fatalf("pass %d: RetryFailedUsers: %v", pass, err)
+		}
+		if resp.Attempted != 0 {
+			t.Errorf("pass %d re-checked %d users within the miss interval", pass, resp.Attempted)
+		}
+	}
+	if store.misses["ghost"] != 1 || store.status["ghost"] != service.AccountMissing {
+		t.Fatalf("account = %s with %d misses after one day, want missing with 1", store.status["ghost"], store.misses["ghost"])
+	}
+
+	store.passTime(cfg.Accounts.MissInterval)
+	if _, err := srv.RetryFailedUsers(ctx, 0); err != nil {
+		t.Fatalf("RetryFailedUsers: %v", err)
+	}
+	if got := store.status["ghost"]; got != service.AccountDeleted {
+		t.Errorf("account = %s after misses on two days, want %s", got, service.AccountDeleted)
+	}
+}
+
+func TestRetryFailedUsers_RenamedAccountIsLinked(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Load()
+	cfg.FailedUsers.MaxAttempts = 5
+	cfg.Accounts.MissesToDelete = 3
+
+	const defaultAvatar = "https://assets.leetcode.com/users/default_avatar.jpg"
+	store := newAccountStore()
+	store.addUser("ghost", "Jane Doe", "US", defaultAvatar, 48*time.Hour)
+	// the slug changed with the rename; name, country and avatar did not
+	store.addUser("jane", "Jane Doe", "US", defaultAvatar, time.Hour)
+	// same name and avatar elsewhere is a different person
+	store.addUser("jane_fr", "Jane Doe", "FR", defaultAvatar, time.Hour)
+	// a shared default avatar alone is no match either
+	store.addUser("someone", "", "US", defaultAvatar, time.Hour)
+	store.failed["ghost"] = &users_storage.FailedUserFetch{Username: "ghost", ErrorClass: "transient", Attempts: 1, Status: service.FailedUserPending}
+	srv := newAccountService(t, cfg, store)
+
+	if _, err := srv.RetryFailedUsers(ctx, 0); err != nil {
+		t.Fatalf("RetryFailedUsers: %v", err)
+	}
+	if got := store.status["ghost"]; got != service.AccountRenamed {
+		t.Fatalf("account = %s, want %s", got, service.AccountRenamed)
+	}
+	if got := store.users["ghost"].RenamedTo.String; got != "jane" {
+		t.Errorf("renamed to %q, want %q", got, "jane")
+	}
+}
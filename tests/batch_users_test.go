@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/errors_"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/fakeleetcode"
+	"github.com/ruziba3vich/leetcode_ranking/internal/service"
+)
+
+func TestFetchMatchedUsers_ThrottledBatchDoesNotFallBack(t *testing.T) {
+	ctx := context.Background()
+	policy := service.RetryPolicy{MaxAttempts: 2}
+
+	fake := fakeleetcode.New()
+	fake.SetSyntheticUsers(10)
+	fake.SetFaults(fakeleetcode.Faults{ThrottleRate: 1})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := config.Load()
+	cfg.URL = srv.URL
+	// keep the adaptive limiter from slowing the test down after the 429s
+	cfg.RequestsPerSecond, cfg.MinRequestsPerSecond, cfg.Burst = 1000, 1000, 100
+	client := service.NewLeetCodeClient(cfg)
+
+	usernames := []string{"synthetic_0000000", "synthetic_0000001", "synthetic_0000002", "synthetic_0000003"}
+	results := client.FetchMatchedUsers(ctx, policy, usernames)
+
+	if got := fake.Requests(); got != int64(policy.MaxAttempts) {
+		t.Errorf("sent %d requests, want %d: a throttled batch must not fall back to single queries", got, policy.MaxAttempts)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, errors_.ErrThrottled) {
+			t.Errorf("%s: err = %v, want throttled", r.Username, r.Err)
+		}
+	}
+}
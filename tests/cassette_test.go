@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/cassette"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/fakeleetcode"
+	"github.com/ruziba3vich/leetcode_ranking/internal/service"
+)
+
+func TestCassette_RecordThenReplay(t *testing.T) {
+	ctx := context.Background()
+	policy := service.RetryPolicy{MaxAttempts: 3}
+	dir := t.TempDir()
+
+	// record against a fake that compresses its responses
+	fake := fakeleetcode.New()
+	if err := fake.LoadRankingFile("fetched_first_page_users.json"); err != nil {
+		t.Fatalf("load ranking fixture: %v", err)
+	}
+	fake.SetFaults(fakeleetcode.Faults{Encoding: fakeleetcode.EncodingGzip})
+	srv := httptest.NewServer(fake)
+
+	cfg := config.Load()
+	cfg.URL = srv.URL
+	cfg.CassetteMode = string(cassette.ModeRecord)
+	cfg.CassetteDir = dir
+	recorded, err := service.NewLeetCodeClient(cfg).FetchRankingPage(ctx, policy, 1)
+	if err != nil {
+		t.Fatalf("record FetchRankingPage(1): %v", err)
+	}
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "globalRanking-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %d globalRanking cassettes, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	if !strings.Contains(string(data), `"fjzzq2002"`) {
+		t.Error("cassette body is not stored decompressed")
+	}
+
+	// replay with the server gone
+	cfg.CassetteMode = string(cassette.ModeReplay)
+	replayer := service.NewLeetCodeClient(cfg)
+	replayed, err := replayer.FetchRankingPage(ctx, policy, 1)
+	if err != nil {
+		t.Fatalf("replay FetchRankingPage(1): %v", err)
+	}
+	got, want := replayed.Data.GlobalRanking.RankingNodes, recorded.Data.GlobalRanking.RankingNodes
+	if len(got) != len(want) {
+		t.Fatalf("replayed %d users, recorded %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].User.Username != want[i].User.Username || got[i].CurrentGlobalRank != want[i].CurrentGlobalRank {
+			t.Errorf("user[%d] = %s #%d, recorded %s #%d", i,
+				got[i].User.Username, got[i].CurrentGlobalRank, want[i].User.Username, want[i].CurrentGlobalRank)
+		}
+	}
+
+	// a request that was never recorded fails at once
+	if _, err := replayer.FetchRankingPage(ctx, policy, 2); !errors.Is(err, cassette.ErrNoRecording) {
+		t.Errorf("replay of an unrecorded page: err = %v, want ErrNoRecording", err)
+	}
+}
@@ -1,20 +1,39 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/fakeleetcode"
 	"github.com/ruziba3vich/leetcode_ranking/internal/service"
 )
 
+// newFakeSource returns a client of a fake LeetCode serving the golden first page
+func newFakeSource(t *testing.T) service.LeetCodeSource {
+	t.Helper()
+	fake := fakeleetcode.New()
+	if err := fake.LoadRankingFile("fetched_first_page_users.json"); err != nil {
+		t.Fatalf("load ranking fixture: %v", err)
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := config.Load()
+	cfg.URL = srv.URL
+	return service.NewLeetCodeClient(cfg)
+}
+
 func TestFetchRankingPage_CompareGolden(t *testing.T) {
-	// ctx := context.Background()
-	srv := GetUserService()
+	ctx := context.Background()
+	source := GetLeetCodeSource()
 
 	// act: fetch first page
-	resp, err := srv.FetchRankingPage(1)
+	resp, err := source.FetchRankingPage(ctx, service.RetryPolicy{MaxAttempts: 3}, 1)
 	if err != nil {
 		t.Fatalf("FetchRankingPage(1) failed: %v", err)
 	}
@@ -7,6 +7,7 @@ import (
 
 	_ "github.com/lib/pq"
 	"github.com/ruziba3vich/leetcode_ranking/db/users_storage"
+	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/cassette"
 	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/config"
 	"github.com/ruziba3vich/leetcode_ranking/internal/pkg/helper"
 	"github.com/ruziba3vich/leetcode_ranking/internal/service"
@@ -15,13 +16,46 @@ import (
 )
 
 type Factory struct {
-	service service.UserService
+	service      service.UserService
+	fetchService service.UserService
+	source       service.LeetCodeSource
 }
 
 var (
 	factory Factory
 )
 
+// cassetteDir holds recorded LeetCode responses, so fetch tests run offline
+const cassetteDir = "testdata/cassettes"
+
+// GetLeetCodeSource returns a client that replays cassetteDir. Run the tests
+// with LEETCODE_CASSETTE_MODE=record to refresh the cassettes from leetcode.com.
+func GetLeetCodeSource() service.LeetCodeSource {
+	if factory.source == nil {
+		cfg := config.Load()
+		if cfg.CassetteMode == "" {
+			cfg.CassetteMode = string(cassette.ModeReplay)
+		}
+		cfg.CassetteDir = cassetteDir
+		factory.source = service.NewLeetCodeClient(cfg)
+	}
+	return factory.source
+}
+
+// GetFetchService returns a service for fetch-only calls such as GetUserData:
+// it reads LeetCode through GetLeetCodeSource and has no database
+func GetFetchService() service.UserService {
+	if factory.fetchService == nil {
+		lgg, err := logger.NewLogger("app.log")
+		if err != nil {
+			log.Fatal(err)
+		}
+		factory.fetchService = service.NewUserService(config.Load(), nil, nil, GetLeetCodeSource(), lgg)
+	}
+	return factory.fetchService
+}
+
+// GetUserService returns a service against live LeetCode and the configured database
 func GetUserService() service.UserService {
 	if factory.service == nil {
 		cfg := config.Load()
@@ -31,9 +65,9 @@ func GetUserService() service.UserService {
 			log.Fatal(err)
 		}
 		db := helper.NewDB(cfg)
-		dbStorage := dbStorage.NewStorage(db)
+		dbStorage := dbStorage.NewStorage(db, cfg)
 		storage := users_storage.New(db)
-		factory.service = service.NewUserService(storage, dbStorage, leetcodeClient, lgg)
+		factory.service = service.NewUserService(cfg, storage, dbStorage, leetcodeClient, lgg)
 	}
 
 	return factory.service
@@ -48,37 +82,33 @@ func (m mockLogger) Infof(format string, args ...interface{})  {}
 func (m mockLogger) Errorf(format string, args ...interface{}) {}
 
 type mockFetcher struct {
-	resp *service.ResponseUser
+	user *service.MatchedUser
 	err  error
 	last string
 }
 
-func (m *mockFetcher) FetchUser(username string) (*service.ResponseUser, error) {
+func (m *mockFetcher) FetchMatchedUser(ctx context.Context, policy service.RetryPolicy, username string) (*service.MatchedUser, error) {
 	m.last = username
-	return m.resp, m.err
+	return m.user, m.err
 }
 
-// helper to make a successful ResponseUser with AC "All"
-func makeOKResp(username string, solved, subs int, countryCode, countryName, realName, slug, avatar, typename string) *service.ResponseUser {
-	return &service.ResponseUser{
-		Data: service.DataUser{
-			MatchedUser: &service.MatchedUser{
-				SubmitStats: service.SubmitStats{
-					ACSubmissionNum: []service.ACStat{
-						{Difficulty: "Easy", Count: 1, Submissions: 2},
-						{Difficulty: "All", Count: solved, Submissions: subs},
-					},
-				},
-				Profile: service.ProfileFull{
-					UserSlug:    slug,
-					UserAvatar:  avatar,
-					CountryCode: countryCode,
-					CountryName: countryName,
-					RealName:    realName,
-					Typename:    typename,
-				},
+// helper to make a successful MatchedUser with AC "All"
+func makeOKResp(username string, solved, subs int, countryCode, countryName, realName, slug, avatar, typename string) *service.MatchedUser {
+	return &service.MatchedUser{
+		SubmitStats: service.SubmitStats{
+			ACSubmissionNum: []service.ACStat{
+				{Difficulty: "Easy", Count: 1, Submissions: 2},
+				{Difficulty: "All", Count: solved, Submissions: subs},
 			},
 		},
+		Profile: service.Profile{
+			UserSlug:    slug,
+			UserAvatar:  avatar,
+			CountryCode: countryCode,
+			CountryName: countryName,
+			RealName:    realName,
+			Typename:    typename,
+		},
 	}
 }
 
@@ -89,12 +119,12 @@ func TestFetchLeetCodeUser_Success(t *testing.T) {
 	// newLCFetcher = func(debug bool, delay time.Duration) lcFetcher { return mf }
 	// t.Cleanup(func() { newLCFetcher = origFactory })
 
-	svc := GetUserService()
+	svc := GetFetchService()
 
 	// act
-	got, err := svc.FetchLeetCodeUser(context.Background(), "neal_wu")
+	got, err := svc.GetUserData(context.Background(), "neal_wu")
 	if err != nil {
-		t.Fatalf("FetchLeetCodeUser error: %v", err)
+		t.Fatalf("GetUserData error: %v", err)
 	}
 
 	// pp.Println(got)
@@ -127,9 +157,9 @@ func TestFetchLeetCodeUser_Success(t *testing.T) {
 }
 
 func TestFetchLeetCodeUser_EmptyUsername(t *testing.T) {
-	svc := GetUserService()
+	svc := GetFetchService()
 
-	_, err := svc.FetchLeetCodeUser(context.Background(), "  ")
+	_, err := svc.GetUserData(context.Background(), "  ")
 	if err == nil {
 		t.Fatal("expected error for empty username, got nil")
 	}
@@ -141,9 +171,9 @@ func TestFetchLeetCodeUser_NoMatchedUser(t *testing.T) {
 	// newLCFetcher = func(debug bool, delay time.Duration) lcFetcher { return mf }
 	// t.Cleanup(func() { newLCFetcher = origFactory })
 
-	svc := GetUserService()
+	svc := GetFetchService()
 
-	_, err := svc.FetchLeetCodeUser(context.Background(), "some_not_available_username")
+	_, err := svc.GetUserData(context.Background(), "some_not_available_username")
 	// pp.Println(resp)
 	if err == nil {
 		t.Fatal("expected error when matchedUser is nil, got nil")
@@ -153,7 +183,7 @@ func TestFetchLeetCodeUser_NoMatchedUser(t *testing.T) {
 // func TestFetchLeetCodeUser_NoAllStat(t *testing.T) {
 // 	// same as OK but remove the "All" difficulty
 // 	resp := makeOKResp("x", 10, 20, "US", "United States", "X", "x", "a.png", "UserProfileNode")
-// 	resp.Data.MatchedUser.SubmitStats.ACSubmissionNum = []service.ACStat{
+// 	resp.SubmitStats.ACSubmissionNum = []service.ACStat{
 // 		{Difficulty: "Easy", Count: 1, Submissions: 2},
 // 	}
 
@@ -162,9 +192,9 @@ func TestFetchLeetCodeUser_NoMatchedUser(t *testing.T) {
 // 	// newLCFetcher = func(debug bool, delay time.Duration) lcFetcher { return mf }
 // 	// t.Cleanup(func() { newLCFetcher = origFactory })
 
-// 	svc := GetUserService()
+// 	svc := GetFetchService()
 
-// 	_, err := svc.FetchLeetCodeUser(context.Background(), "x")
+// 	_, err := svc.GetUserData(context.Background(), "x")
 // 	if err == nil {
 // 		t.Fatal("expected error when AC 'All' stat is missing, got nil")
 // 	}
@@ -176,9 +206,9 @@ func TestFetchLeetCodeUser_RemoteError(t *testing.T) {
 	// newLCFetcher = func(debug bool, delay time.Duration) lcFetcher { return mf }
 	// t.Cleanup(func() { newLCFetcher = origFactory })
 
-	svc := GetUserService()
+	svc := GetFetchService()
 
-	_, err := svc.FetchLeetCodeUser(context.Background(), "__any")
+	_, err := svc.GetUserData(context.Background(), "__any")
 
 	// pp.Println(resp)
 	if err == nil {